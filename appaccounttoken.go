@@ -0,0 +1,5 @@
+package appstore
+
+type UpdateAppAccountTokenRequest struct {
+	AppAccountToken string `json:"appAccountToken"`
+}
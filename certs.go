@@ -0,0 +1,106 @@
+package appstore
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// WithAppleRootCerts trusts the given Apple root certificates, e.g. Apple Root
+// CA - G2 and G3. Each argument may be PEM, holding one or more certificates,
+// or DER.
+func WithAppleRootCerts(certs ...[]byte) (ClientOption, error) {
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("appleapi: no Apple root certificates given")
+	}
+	var roots []*x509.Certificate
+	for i, data := range certs {
+		parsed, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("appleapi: root certificate argument %d: %v", i, err)
+		}
+		roots = append(roots, parsed...)
+	}
+	return withRootCerts(roots), nil
+}
+
+func withRootCerts(certs []*x509.Certificate) ClientOption {
+	return func(c *Client) {
+		if c.verifyOptions == nil {
+			c.verifyOptions = &x509.VerifyOptions{}
+		}
+		if c.verifyOptions.Roots == nil {
+			c.verifyOptions.Roots = x509.NewCertPool()
+		}
+		for _, cert := range certs {
+			c.verifyOptions.Roots.AddCert(cert)
+		}
+	}
+}
+
+// WithAppleCerts trusts the root certificate at rootCertPath. Both files may
+// be PEM or DER. The intermediate certificate is accepted for compatibility;
+// the intermediate used to verify a payload is the one it carries.
+func WithAppleCerts(intermCertPath, rootCertPath string) (ClientOption, error) {
+	if _, err := readCertificateFile(intermCertPath); err != nil {
+		return nil, fmt.Errorf("appleapi: intermediate certificate (first argument): %v", err)
+	}
+	roots, err := readCertificateFile(rootCertPath)
+	if err != nil {
+		return nil, fmt.Errorf("appleapi: root certificate (second argument): %v", err)
+	}
+	for _, root := range roots {
+		if !isSelfSigned(root) {
+			return nil, fmt.Errorf("appleapi: root certificate (second argument): %s is not a root certificate",
+				root.Subject.CommonName)
+		}
+	}
+	return withRootCerts(roots), nil
+}
+
+func readCertificateFile(name string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := parseCertificates(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return certs, nil
+}
+
+// parseCertificates parses data as one or more PEM certificates, falling
+// back to a single DER certificate.
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("unexpected PEM block type %q", block.Type)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse PEM certificate: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) > 0 {
+		return certs, nil
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a PEM or DER certificate: %v", err)
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return cert.CheckSignatureFrom(cert) == nil
+}
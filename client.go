@@ -3,19 +3,20 @@ package appstore
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
 
 	"github.com/erictse/appstore-go/notification"
 	"github.com/erictse/appstore-go/refund"
+	"github.com/erictse/appstore-go/subscription"
 	"github.com/erictse/appstore-go/transaction"
 	"github.com/golang-jwt/jwt/v4"
 )
@@ -34,6 +35,10 @@ const (
 	pathSubscriptionStatuses    = "/inApps/v1/subscriptions/"
 	pathTestNotificationStatus  = "/inApps/v1/notifications/test/"
 	pathTransactionHistory      = "/inApps/v1/history/"
+	pathTransactionHistoryV2    = "/inApps/v2/history/"
+	pathTransactionInfo         = "/inApps/v1/transactions/"
+	pathAppAccountToken         = "/appAccountToken"
+	pathAppTransactionInfo      = "/inApps/v1/transactions/appTransactions/"
 
 	headerAuthorization = "Authorization"
 	headerContentType   = "Content-Type"
@@ -50,15 +55,17 @@ type AppleAPIClaims struct {
 type Client struct {
 	httpClient *http.Client
 
-	certAppleInterm *x509.Certificate
-	certAppleRoot   *x509.Certificate
-	claims          *AppleAPIClaims
-	host            *string
-	keyFunc         jwt.Keyfunc
-	keyID           *string
-	privateKey      *ecdsa.PrivateKey
-	teamID          *string
-	verifyOptions   *x509.VerifyOptions
+	appAppleID    int64
+	claims        *AppleAPIClaims
+	environment   Environment
+	host          *string
+	keyFunc       jwt.Keyfunc
+	keyID         *string
+	signer        crypto.Signer
+	teamID        *string
+	tokens        *tokenSource
+	verifierOpts  []VerifierOption
+	verifyOptions *x509.VerifyOptions
 }
 
 type JWSDecoder interface {
@@ -71,6 +78,35 @@ func WithSandbox() ClientOption {
 	return func(c *Client) {
 		host := hostSandbox
 		c.host = &host
+		c.environment = EnvironmentSandbox
+	}
+}
+
+// WithTokenRefreshMargin sets how long before expiry the cached API bearer
+// token is replaced. The default is one minute, which is also used for
+// margins outside the token's 15 minute lifetime.
+func WithTokenRefreshMargin(margin time.Duration) ClientOption {
+	return func(c *Client) {
+		if margin < 0 || margin >= tokenLifetime {
+			margin = defaultTokenRefreshMargin
+		}
+		c.tokens.margin = margin
+	}
+}
+
+// WithAppAppleID sets the app Apple ID that signed payloads are checked
+// against. Apps that have not been released have no app Apple ID.
+func WithAppAppleID(appAppleID int64) ClientOption {
+	return func(c *Client) {
+		c.appAppleID = appAppleID
+	}
+}
+
+// WithVerifierOptions configures how the client verifies signed payloads in
+// API responses, e.g. WithOCSP.
+func WithVerifierOptions(opts ...VerifierOption) ClientOption {
+	return func(c *Client) {
+		c.verifierOpts = append(c.verifierOpts, opts...)
 	}
 }
 
@@ -79,73 +115,49 @@ func WithClaimsAndKey(bundleID, issuerID, keyID, teamID string, keyPEM []byte) (
 	if parseErr != nil {
 		return nil, fmt.Errorf("appleapi: could not read private key: %v", parseErr)
 	}
+	return WithClaimsAndSigner(bundleID, issuerID, keyID, teamID, key)
+}
+
+// WithClaimsAndSigner is like WithClaimsAndKey for an In-App Purchase key that
+// is only reachable through a crypto.Signer, e.g. one held in a KMS or HSM.
+// The signer must hold a P-256 ECDSA key and return ASN.1 DER signatures.
+func WithClaimsAndSigner(bundleID, issuerID, keyID, teamID string, signer crypto.Signer) (ClientOption, error) {
+	pub, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || pub.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("appleapi: signer key must be a P-256 ECDSA key")
+	}
 	return func(c *Client) {
 		c.claims.Issuer = issuerID
 		c.claims.BundleID = bundleID
 		c.keyID = &keyID
-		c.privateKey = key
+		c.signer = signer
 		c.teamID = &teamID
 	}, nil
 }
 
-func WithAppleCerts(intermCertPath, rootCertPath string) (ClientOption, error) {
-	intermCertDER, err := os.ReadFile(intermCertPath)
-	if err != nil {
-		return nil, err
-	}
-	intermCert, err := x509.ParseCertificate(intermCertDER)
-	if err != nil {
-		return nil, err
-	}
-	intermPool := x509.NewCertPool()
-	intermPool.AddCert(intermCert)
-
-	certAppleRootDER, err := os.ReadFile(rootCertPath)
-	if err != nil {
-		return nil, err
-	}
-	rootCert, err := x509.ParseCertificate(certAppleRootDER)
-	if err != nil {
-		return nil, err
-	}
-	rootPool := x509.NewCertPool()
-	rootPool.AddCert(rootCert)
-
-	return func(c *Client) {
-		c.verifyOptions = &x509.VerifyOptions{
-			Intermediates: intermPool,
-			Roots:         rootPool,
-		}
-		c.certAppleInterm = intermCert
-		c.certAppleRoot = rootCert
-	}, nil
-}
-
 func NewClient(opts ...ClientOption) (*Client, error) {
 	host := hostProd
-	keyFunc := func(t *jwt.Token) (any, error) {
-		if multi, ok := t.Header["x5c"].([]any); !ok {
-			return nil, fmt.Errorf("cert not found in JWS header")
-		} else if encoded, ok := multi[0].(string); !ok {
-			return nil, fmt.Errorf("invalid cert format in JWS header")
-		} else if decoded, err := base64.StdEncoding.DecodeString(encoded); err != nil {
-			return nil, fmt.Errorf("unable base64 decode JWS cert: %v", err)
-		} else if cert, err := x509.ParseCertificate(decoded); err != nil {
-			return nil, fmt.Errorf("could not parse JWS cert: %v", err)
-		} else {
-			return cert.PublicKey, nil
-		}
-	}
 	c := &Client{
-		httpClient: &http.Client{},
-		claims:     &AppleAPIClaims{},
-		host:       &host,
-		keyFunc:    keyFunc,
+		httpClient:  &http.Client{},
+		claims:      &AppleAPIClaims{},
+		environment: EnvironmentProduction,
+		host:        &host,
+	}
+	c.tokens = &tokenSource{
+		margin: defaultTokenRefreshMargin,
+		sign:   c.signToken,
 	}
 	c.claims.RegisteredClaims.Audience = []string{"appstoreconnect-v1"}
 	for _, opt := range opts {
 		opt(c)
 	}
+	verifier := &SignedDataVerifier{
+		appAppleID:   c.appAppleID,
+		bundleID:     c.claims.BundleID,
+		environment:  c.environment,
+		chainKeyFunc: newKeyFunc(c.verifyOptions, c.verifierOpts...),
+	}
+	c.keyFunc = verifier.keyFunc
 	return c, nil
 }
 
@@ -153,17 +165,30 @@ func (c Client) endpoint(path string) string {
 	return *c.host + path
 }
 
+// InvalidateToken discards the cached API bearer token so the next request
+// signs a new one. The client calls it after a 401 response.
+func (c *Client) InvalidateToken() {
+	c.tokens.Invalidate()
+}
+
 func (c Client) newClaims(issuedAt time.Time) jwt.Claims {
-	claims := c.claims
+	claims := *c.claims
 	claims.RegisteredClaims.IssuedAt = jwt.NewNumericDate(issuedAt)
-	claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(issuedAt.Add(time.Minute * 15))
-	return claims
+	claims.RegisteredClaims.ExpiresAt = jwt.NewNumericDate(issuedAt.Add(tokenLifetime))
+	return &claims
 }
 
-func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodES256, c.newClaims(time.Now()))
+func (c *Client) signToken(issuedAt time.Time) (string, error) {
+	if c.signer == nil {
+		return "", fmt.Errorf("appleapi: no key configured, see WithClaimsAndKey")
+	}
+	token := jwt.NewWithClaims(signingMethodES256Signer, c.newClaims(issuedAt))
 	token.Header["kid"] = c.keyID
-	jwtString, err := token.SignedString(c.privateKey)
+	return token.SignedString(c.signer)
+}
+
+func (c *Client) newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	jwtString, err := c.tokens.Token()
 	if err != nil {
 		return nil, err
 	}
@@ -191,12 +216,16 @@ func (c *Client) send(req *http.Request, decoder JWSDecoder) error {
 	}
 
 	switch resp.StatusCode {
-	case http.StatusOK:
+	case http.StatusOK, http.StatusAccepted:
+		if decoder == nil {
+			return nil
+		}
 		if err := decoder.DecodeJWS(c.keyFunc, data); err != nil {
 			return err
 		}
 		return nil
 	case http.StatusUnauthorized:
+		c.InvalidateToken()
 		return fmt.Errorf("JWT authorization header is invalid: %s", req.Header.Get(headerAuthorization))
 	}
 	var payload Error
@@ -217,11 +246,26 @@ func (c *Client) ExtendRenewalDate(ctx context.Context, originalTransactionID st
 	uri := c.endpoint(pathSubscriptionExtend + originalTransactionID)
 	req, err := c.newRequest(ctx, http.MethodPut, uri, bytes.NewReader(data))
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client ExtendRenewalDate: %v", err)
+		return r, fmt.Errorf("appleapi: client ExtendRenewalDate: %w", err)
 	}
 	req.Header.Set(headerContentType, contentTypeJSON)
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client ExtendRenewalDate: %v", err)
+		return r, fmt.Errorf("appleapi: client ExtendRenewalDate: %w", err)
+	}
+	return r, nil
+}
+
+// GetAppTransactionInfo looks up the app transaction of the customer who made
+// the in-app purchase with transactionID.
+func (c *Client) GetAppTransactionInfo(ctx context.Context, transactionID string) (AppTransactionInfoResponse, error) {
+	var r AppTransactionInfoResponse
+	uri := c.endpoint(pathAppTransactionInfo + transactionID)
+	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return r, fmt.Errorf("appleapi: client GetAppTransactionInfo: %w", err)
+	}
+	if err := c.send(req, &r); err != nil {
+		return r, fmt.Errorf("appleapi: client GetAppTransactionInfo: %w", err)
 	}
 	return r, nil
 }
@@ -231,10 +275,10 @@ func (c *Client) GetMassExtendRenewalDateStatus(ctx context.Context, productID,
 	uri := c.endpoint(pathSubscriptionMassExtend + requestID + "/" + productID)
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client GetMassExtendRenewalDateStatus: %v", err)
+		return r, fmt.Errorf("appleapi: client GetMassExtendRenewalDateStatus: %w", err)
 	}
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client GetMassExtendRenewalDateStatus: %v", err)
+		return r, fmt.Errorf("appleapi: client GetMassExtendRenewalDateStatus: %w", err)
 	}
 	return r, nil
 }
@@ -260,11 +304,11 @@ func (c *Client) GetNotificationHistory(ctx context.Context, start, end time.Tim
 	}
 	req, err := c.newRequest(ctx, http.MethodPost, uri, buf)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client GetNotificationHistory: %v", err)
+		return r, fmt.Errorf("appleapi: client GetNotificationHistory: %w", err)
 	}
 	req.Header.Set(headerContentType, contentTypeJSON)
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client GetNotificationHistory: %v", err)
+		return r, fmt.Errorf("appleapi: client GetNotificationHistory: %w", err)
 	}
 	return r, nil
 }
@@ -281,24 +325,31 @@ func (c *Client) GetRefundHistory(ctx context.Context, originalTransactionID str
 	}
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client GetRefundHistory: %v", err)
+		return r, fmt.Errorf("appleapi: client GetRefundHistory: %w", err)
 	}
 	req.Header.Set(headerContentType, contentTypeJSON)
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client GetRefundHistory: %v", err)
+		return r, fmt.Errorf("appleapi: client GetRefundHistory: %w", err)
 	}
 	return r, nil
 }
 
-func (c *Client) GetSubscriptionStatuses(ctx context.Context, originalTransactionID string) (StatusResponse, error) {
+func (c *Client) GetSubscriptionStatuses(ctx context.Context, originalTransactionID string, opts ...subscription.StatusOption) (StatusResponse, error) {
 	var r StatusResponse
+	query := url.Values{}
+	for _, opt := range opts {
+		opt(&query)
+	}
 	uri := c.endpoint(pathSubscriptionStatuses + originalTransactionID)
+	if len(query) > 0 {
+		uri = fmt.Sprintf("%s?%s", uri, query.Encode())
+	}
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client GetSubscriptionStatuses: %v", err)
+		return r, fmt.Errorf("appleapi: client GetSubscriptionStatuses: %w", err)
 	}
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client GetSubscriptionStatuses: %v", err)
+		return r, fmt.Errorf("appleapi: client GetSubscriptionStatuses: %w", err)
 	}
 	return r, nil
 }
@@ -308,30 +359,60 @@ func (c *Client) GetTestNotificationStatus(ctx context.Context, token string) (C
 	uri := c.endpoint(pathTestNotificationStatus + token)
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return resp, fmt.Errorf("appleapi: client RequestTestNotification: %v", err)
+		return resp, fmt.Errorf("appleapi: client RequestTestNotification: %w", err)
 	}
 	if err := c.send(req, &resp); err != nil {
-		return resp, fmt.Errorf("appleapi: client RequestTestNotification: %v", err)
+		return resp, fmt.Errorf("appleapi: client RequestTestNotification: %w", err)
 	}
 	return resp, nil
 }
 
+// Deprecated: Apple deprecated this endpoint, use GetTransactionHistoryV2.
 func (c *Client) GetTransactionHistory(ctx context.Context, originalTransactionID string, opts ...transaction.HistoryOption) (HistoryResponse, error) {
+	r, err := c.getTransactionHistory(ctx, pathTransactionHistory, originalTransactionID, opts...)
+	if err != nil {
+		return r, fmt.Errorf("appleapi: client GetTransactionHistory: %w", err)
+	}
+	return r, nil
+}
+
+func (c *Client) GetTransactionHistoryV2(ctx context.Context, transactionID string, opts ...transaction.HistoryOption) (HistoryResponse, error) {
+	r, err := c.getTransactionHistory(ctx, pathTransactionHistoryV2, transactionID, opts...)
+	if err != nil {
+		return r, fmt.Errorf("appleapi: client GetTransactionHistoryV2: %w", err)
+	}
+	return r, nil
+}
+
+func (c *Client) getTransactionHistory(ctx context.Context, path, transactionID string, opts ...transaction.HistoryOption) (HistoryResponse, error) {
 	var r HistoryResponse
 	query := url.Values{}
 	for _, opt := range opts {
 		opt(&query)
 	}
-	uri := c.endpoint(pathTransactionHistory + originalTransactionID)
+	uri := c.endpoint(path + transactionID)
 	if len(query) > 0 {
 		uri = fmt.Sprintf("%s?%s", uri, query.Encode())
 	}
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client GetTransactionHistory: %v", err)
+		return r, err
+	}
+	if err := c.send(req, &r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func (c *Client) GetTransactionInfo(ctx context.Context, transactionID string) (TransactionInfoResponse, error) {
+	var r TransactionInfoResponse
+	uri := c.endpoint(pathTransactionInfo + transactionID)
+	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return r, fmt.Errorf("appleapi: client GetTransactionInfo: %w", err)
 	}
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client GetTransactionHistory: %v", err)
+		return r, fmt.Errorf("appleapi: client GetTransactionInfo: %w", err)
 	}
 	return r, nil
 }
@@ -341,10 +422,10 @@ func (c *Client) LookupOrder(ctx context.Context, orderID string) (OrderLookupRe
 	uri := c.endpoint(pathOrderLookup + orderID)
 	req, err := c.newRequest(ctx, http.MethodGet, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client create request LookupOrder: %v", err)
+		return r, fmt.Errorf("appleapi: client create request LookupOrder: %w", err)
 	}
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client send LookupOrder: %v", err)
+		return r, fmt.Errorf("appleapi: client send LookupOrder: %w", err)
 	}
 	return r, nil
 }
@@ -358,11 +439,11 @@ func (c *Client) MassExtendRenewalDates(ctx context.Context, body MassExtendRene
 	uri := c.endpoint(pathSubscriptionMassExtend)
 	req, err := c.newRequest(ctx, http.MethodPost, uri, bytes.NewReader(data))
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client MassExtendRenewalDates: %v", err)
+		return r, fmt.Errorf("appleapi: client MassExtendRenewalDates: %w", err)
 	}
 	req.Header.Set(headerContentType, contentTypeJSON)
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client MassExtendRenewalDates: %v", err)
+		return r, fmt.Errorf("appleapi: client MassExtendRenewalDates: %w", err)
 	}
 	return r, nil
 }
@@ -372,16 +453,20 @@ func (c *Client) RequestTestNotification(ctx context.Context) (SendTestNotificat
 	uri := c.endpoint(pathRequestTestNotification)
 	req, err := c.newRequest(ctx, http.MethodPost, uri, nil)
 	if err != nil {
-		return r, fmt.Errorf("appleapi: client RequestTestNotification: %v", err)
+		return r, fmt.Errorf("appleapi: client RequestTestNotification: %w", err)
 	}
 	if err := c.send(req, &r); err != nil {
-		return r, fmt.Errorf("appleapi: client RequestTestNotification: %v", err)
+		return r, fmt.Errorf("appleapi: client RequestTestNotification: %w", err)
 	}
 	return r, nil
 }
 
+// SendConsumptionInfo answers a CONSUMPTION_REQUEST notification. body is
+// checked with Validate before it is sent.
 func (c *Client) SendConsumptionInfo(ctx context.Context, originalTransactionID string, body ConsumptionRequest) error {
-	var r SendTestNotificationResponse
+	if err := body.Validate(); err != nil {
+		return err
+	}
 	uri := c.endpoint(pathSendConsumptionInfo + originalTransactionID)
 	data, jsonErr := json.Marshal(body)
 	if jsonErr != nil {
@@ -389,11 +474,32 @@ func (c *Client) SendConsumptionInfo(ctx context.Context, originalTransactionID
 	}
 	req, err := c.newRequest(ctx, http.MethodPut, uri, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("appleapi: client SendConsumptionInfo: %v", err)
+		return fmt.Errorf("appleapi: client SendConsumptionInfo: %w", err)
 	}
 	req.Header.Set(headerContentType, contentTypeJSON)
-	if err := c.send(req, &r); err != nil {
-		return fmt.Errorf("appleapi: client SendConsumptionInfo: %v", err)
+	if err := c.send(req, nil); err != nil {
+		return fmt.Errorf("appleapi: client SendConsumptionInfo: %w", err)
+	}
+	return nil
+}
+
+// SetAppAccountToken sets the appAccountToken of a purchase, e.g. when it was
+// missing or wrong at purchase time. Errors include
+// ErrInvalidAppAccountTokenUUID, ErrInvalidTransactionTypeNotSupported and
+// ErrOriginalTransactionIDNotFound.
+func (c *Client) SetAppAccountToken(ctx context.Context, originalTransactionID string, body UpdateAppAccountTokenRequest) error {
+	data, jsonErr := json.Marshal(body)
+	if jsonErr != nil {
+		return jsonErr
+	}
+	uri := c.endpoint(pathTransactionInfo + originalTransactionID + pathAppAccountToken)
+	req, err := c.newRequest(ctx, http.MethodPut, uri, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("appleapi: client SetAppAccountToken: %w", err)
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	if err := c.send(req, nil); err != nil {
+		return fmt.Errorf("appleapi: client SetAppAccountToken: %w", err)
 	}
 	return nil
 }
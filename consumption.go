@@ -1,15 +1,145 @@
 package appstore
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ConsumptionRequest answers a CONSUMPTION_REQUEST notification. Apple
+// rejects the whole request unless CustomerConsented is true and every field
+// holds one of the values below, see Validate.
 type ConsumptionRequest struct {
-	AccountTenure            int32  `json:"accountTenure"`
-	AppAccountToken          string `json:"appAccountToken"`
-	ConsumptionStatus        int32  `json:"consumptionStatus"`
-	CustomerConsented        bool   `json:"customerConsented"`
-	DeliveryStatus           int32  `json:"deliveryStatus"`
-	LifetimeDollarsPurchased int32  `json:"lifetimeDollarsPurchased"`
-	LifetimeDollarsRefunded  int32  `json:"lifetimeDollarsRefunded"`
-	Platform                 int32  `json:"platform"`
-	PlayTime                 int32  `json:"playTime"`
-	SampleContentProvided    bool   `json:"sampleContentProvided"`
-	UserStatus               int32  `json:"userStatus"`
+	AccountTenure            AccountTenure     `json:"accountTenure"`
+	AppAccountToken          string            `json:"appAccountToken"`
+	ConsumptionStatus        ConsumptionStatus `json:"consumptionStatus"`
+	CustomerConsented        bool              `json:"customerConsented"`
+	DeliveryStatus           DeliveryStatus    `json:"deliveryStatus"`
+	LifetimeDollarsPurchased LifetimeDollars   `json:"lifetimeDollarsPurchased"`
+	LifetimeDollarsRefunded  LifetimeDollars   `json:"lifetimeDollarsRefunded"`
+	Platform                 Platform          `json:"platform"`
+	PlayTime                 PlayTime          `json:"playTime"`
+	RefundPreference         RefundPreference  `json:"refundPreference"`
+	SampleContentProvided    bool              `json:"sampleContentProvided"`
+	UserStatus               UserStatus        `json:"userStatus"`
+}
+
+func (r ConsumptionRequest) Validate() error {
+	var errs []error
+	if !r.CustomerConsented {
+		errs = append(errs, errors.New("customerConsented must be true"))
+	}
+	if r.AppAccountToken != "" && !isUUID(r.AppAccountToken) {
+		errs = append(errs, fmt.Errorf("appAccountToken %q is not a UUID", r.AppAccountToken))
+	}
+	for _, f := range []struct {
+		name string
+		val  int32
+		max  int32
+	}{
+		{"accountTenure", int32(r.AccountTenure), int32(AccountTenureOver365Days)},
+		{"consumptionStatus", int32(r.ConsumptionStatus), int32(ConsumptionStatusFullyConsumed)},
+		{"deliveryStatus", int32(r.DeliveryStatus), int32(DeliveryStatusOther)},
+		{"lifetimeDollarsPurchased", int32(r.LifetimeDollarsPurchased), int32(LifetimeDollarsOver2000)},
+		{"lifetimeDollarsRefunded", int32(r.LifetimeDollarsRefunded), int32(LifetimeDollarsOver2000)},
+		{"platform", int32(r.Platform), int32(PlatformNonApple)},
+		{"playTime", int32(r.PlayTime), int32(PlayTimeOver16Days)},
+		{"refundPreference", int32(r.RefundPreference), int32(RefundPreferenceNoPreference)},
+		{"userStatus", int32(r.UserStatus), int32(UserStatusLimitedAccess)},
+	} {
+		if f.val < 0 || f.val > f.max {
+			errs = append(errs, fmt.Errorf("%s %d is out of range 0-%d", f.name, f.val, f.max))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("appleapi: invalid consumption request: %w", errors.Join(errs...))
+	}
+	return nil
 }
+
+type AccountTenure int32
+
+const (
+	AccountTenureUndeclared   AccountTenure = 0
+	AccountTenure0To3Days     AccountTenure = 1
+	AccountTenure3To10Days    AccountTenure = 2
+	AccountTenure10To30Days   AccountTenure = 3
+	AccountTenure30To90Days   AccountTenure = 4
+	AccountTenure90To180Days  AccountTenure = 5
+	AccountTenure180To365Days AccountTenure = 6
+	AccountTenureOver365Days  AccountTenure = 7
+)
+
+type ConsumptionStatus int32
+
+const (
+	ConsumptionStatusUndeclared        ConsumptionStatus = 0
+	ConsumptionStatusNotConsumed       ConsumptionStatus = 1
+	ConsumptionStatusPartiallyConsumed ConsumptionStatus = 2
+	ConsumptionStatusFullyConsumed     ConsumptionStatus = 3
+)
+
+type DeliveryStatus int32
+
+const (
+	DeliveryStatusDelivered      DeliveryStatus = 0
+	DeliveryStatusQualityIssue   DeliveryStatus = 1
+	DeliveryStatusWrongItem      DeliveryStatus = 2
+	DeliveryStatusServerOutage   DeliveryStatus = 3
+	DeliveryStatusCurrencyChange DeliveryStatus = 4
+	DeliveryStatusOther          DeliveryStatus = 5
+)
+
+// LifetimeDollars buckets the amount a customer spent or had refunded across
+// all platforms, in USD.
+type LifetimeDollars int32
+
+const (
+	LifetimeDollarsUndeclared LifetimeDollars = 0
+	LifetimeDollarsZero       LifetimeDollars = 1
+	LifetimeDollars1To49      LifetimeDollars = 2
+	LifetimeDollars50To99     LifetimeDollars = 3
+	LifetimeDollars100To499   LifetimeDollars = 4
+	LifetimeDollars500To999   LifetimeDollars = 5
+	LifetimeDollars1000To1999 LifetimeDollars = 6
+	LifetimeDollarsOver2000   LifetimeDollars = 7
+)
+
+type Platform int32
+
+const (
+	PlatformUndeclared Platform = 0
+	PlatformApple      Platform = 1
+	PlatformNonApple   Platform = 2
+)
+
+type PlayTime int32
+
+const (
+	PlayTimeUndeclared   PlayTime = 0
+	PlayTime0To5Minutes  PlayTime = 1
+	PlayTime5To60Minutes PlayTime = 2
+	PlayTime1To6Hours    PlayTime = 3
+	PlayTime6To24Hours   PlayTime = 4
+	PlayTime1To4Days     PlayTime = 5
+	PlayTime4To16Days    PlayTime = 6
+	PlayTimeOver16Days   PlayTime = 7
+)
+
+type RefundPreference int32
+
+const (
+	RefundPreferenceUndeclared   RefundPreference = 0
+	RefundPreferenceGrant        RefundPreference = 1
+	RefundPreferenceDecline      RefundPreference = 2
+	RefundPreferenceNoPreference RefundPreference = 3
+)
+
+type UserStatus int32
+
+const (
+	UserStatusUndeclared    UserStatus = 0
+	UserStatusActive        UserStatus = 1
+	UserStatusSuspended     UserStatus = 2
+	UserStatusTerminated    UserStatus = 3
+	UserStatusLimitedAccess UserStatus = 4
+)
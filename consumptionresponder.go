@@ -0,0 +1,190 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	consumptionResponseWindow     = 12 * time.Hour
+	defaultConsumptionMaxAttempts = 5
+	defaultConsumptionBackoff     = time.Second
+)
+
+// ErrConsumptionDeadlinePassed is returned for CONSUMPTION_REQUEST
+// notifications older than Apple's 12 hour deadline, which can no longer be
+// answered.
+var ErrConsumptionDeadlinePassed = errors.New("consumption request deadline passed")
+
+// ConsumptionProvider assembles the consumption info for a transaction a
+// customer asked Apple to refund.
+type ConsumptionProvider interface {
+	ConsumptionInfo(ctx context.Context, transaction JWSTransactionDecodedPayload) (ConsumptionRequest, error)
+}
+
+// ConsumptionFailure records a CONSUMPTION_REQUEST notification that could
+// not be answered.
+type ConsumptionFailure struct {
+	Payload  *ResponseBodyV2DecodedPayload
+	Attempts int
+	Err      error
+	FailedAt time.Time
+}
+
+// ConsumptionResponder answers CONSUMPTION_REQUEST notifications with
+// SendConsumptionInfo before Apple's 12 hour deadline. It is safe for
+// concurrent use.
+type ConsumptionResponder struct {
+	client      *Client
+	provider    ConsumptionProvider
+	maxAttempts int
+	backoff     time.Duration
+	onFailure   func(ConsumptionFailure)
+
+	mu       sync.Mutex
+	failures []ConsumptionFailure
+}
+
+type ConsumptionResponderOption func(*ConsumptionResponder)
+
+// WithConsumptionRetries sets how often sending is attempted and the delay
+// before the first retry, which doubles after each attempt. The default is 5
+// attempts starting at one second.
+func WithConsumptionRetries(maxAttempts int, backoff time.Duration) ConsumptionResponderOption {
+	return func(r *ConsumptionResponder) {
+		r.maxAttempts = maxAttempts
+		r.backoff = backoff
+	}
+}
+
+// WithConsumptionFailureHook calls fn for every failure as it is recorded,
+// e.g. to persist it for replay after a restart.
+func WithConsumptionFailureHook(fn func(ConsumptionFailure)) ConsumptionResponderOption {
+	return func(r *ConsumptionResponder) {
+		r.onFailure = fn
+	}
+}
+
+func NewConsumptionResponder(c *Client, provider ConsumptionProvider, opts ...ConsumptionResponderOption) *ConsumptionResponder {
+	r := &ConsumptionResponder{
+		client:      c,
+		provider:    provider,
+		maxAttempts: defaultConsumptionMaxAttempts,
+		backoff:     defaultConsumptionBackoff,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Respond answers p if it is a CONSUMPTION_REQUEST notification and reports
+// whether it was one. Failures are recorded, see Failures and Replay, unless
+// the deadline has passed already.
+func (r *ConsumptionResponder) Respond(ctx context.Context, p *ResponseBodyV2DecodedPayload) (bool, error) {
+	if p.NotificationType != NotificationTypeConsumptionRequest {
+		return false, nil
+	}
+	attempts, err := r.respond(ctx, p)
+	if err != nil {
+		if !errors.Is(err, ErrConsumptionDeadlinePassed) {
+			r.record(ConsumptionFailure{Payload: p, Attempts: attempts, Err: err, FailedAt: time.Now()})
+		}
+		return true, fmt.Errorf("appleapi: consumption responder: %w", err)
+	}
+	return true, nil
+}
+
+// consumptionDeadline is when Apple stops accepting consumption info for p.
+func consumptionDeadline(p *ResponseBodyV2DecodedPayload) time.Time {
+	if p.SignedDate.IsZero() {
+		return time.Now().Add(consumptionResponseWindow)
+	}
+	return p.SignedDate.Add(consumptionResponseWindow)
+}
+
+func (r *ConsumptionResponder) respond(ctx context.Context, p *ResponseBodyV2DecodedPayload) (int, error) {
+	if p.Data == nil || p.Data.TransactionInfo.TransactionID == "" {
+		return 0, errors.New("notification has no transaction")
+	}
+	deadline := consumptionDeadline(p)
+	if !time.Now().Before(deadline) {
+		return 0, ErrConsumptionDeadlinePassed
+	}
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	body, err := r.provider.ConsumptionInfo(ctx, p.Data.TransactionInfo)
+	if err != nil {
+		return 0, fmt.Errorf("provider: %w", err)
+	}
+	if err := body.Validate(); err != nil {
+		return 0, err
+	}
+	backoff := r.backoff
+	attempts := 0
+	for {
+		attempts++
+		err = r.client.SendConsumptionInfo(ctx, p.Data.TransactionInfo.TransactionID, body)
+		if err == nil || attempts >= r.maxAttempts || !retryable(err) {
+			return attempts, err
+		}
+		select {
+		case <-ctx.Done():
+			return attempts, fmt.Errorf("%v: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether sending again may succeed.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr Error
+	if errors.As(err, &apiErr) {
+		// 5xxxxxx codes are server errors.
+		return apiErr.Is(ErrRateLimitExceeded) || apiErr.ErrorCode >= 5000000
+	}
+	return true
+}
+
+func (r *ConsumptionResponder) record(f ConsumptionFailure) {
+	r.mu.Lock()
+	r.failures = append(r.failures, f)
+	r.mu.Unlock()
+	if r.onFailure != nil {
+		r.onFailure(f)
+	}
+}
+
+// Failures returns the recorded failures that have not been replayed
+// successfully.
+func (r *ConsumptionResponder) Failures() []ConsumptionFailure {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ConsumptionFailure(nil), r.failures...)
+}
+
+// Replay answers the recorded failures again. Those that fail again stay
+// recorded and their errors are returned joined. Failures past the deadline
+// are dropped and reported with ErrConsumptionDeadlinePassed.
+func (r *ConsumptionResponder) Replay(ctx context.Context) error {
+	r.mu.Lock()
+	failures := r.failures
+	r.failures = nil
+	r.mu.Unlock()
+
+	var errs []error
+	for _, f := range failures {
+		if _, err := r.Respond(ctx, f.Payload); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
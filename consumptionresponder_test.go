@@ -0,0 +1,31 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConsumptionResponderReplayDropsExpired(t *testing.T) {
+	r := NewConsumptionResponder(nil, nil)
+	p := &ResponseBodyV2DecodedPayload{
+		NotificationType: NotificationTypeConsumptionRequest,
+		NotificationUUID: "4a9a8ea5-5a25-4d53-93ef-4f1e1b7b3f0a",
+		Data: &ResponseBodyV2DecodedPayloadData{
+			TransactionInfo: JWSTransactionDecodedPayload{TransactionID: "2000000000000001"},
+		},
+		SignedDate: Millistamp{Time: time.Now().Add(-13 * time.Hour)},
+	}
+	r.record(ConsumptionFailure{Payload: p, Attempts: 5, Err: errors.New("unavailable"), FailedAt: time.Now()})
+
+	if err := r.Replay(context.Background()); !errors.Is(err, ErrConsumptionDeadlinePassed) {
+		t.Fatalf("got %v, want ErrConsumptionDeadlinePassed", err)
+	}
+	if n := len(r.Failures()); n != 0 {
+		t.Fatalf("got %d failures after replay, want 0", n)
+	}
+	if err := r.Replay(context.Background()); err != nil {
+		t.Fatalf("second replay: got %v, want nil", err)
+	}
+}
@@ -0,0 +1,87 @@
+package appstore
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// DeviceVerificationError reports a signed transaction or AppTransaction that
+// cannot be shown to belong to the device that sent it.
+type DeviceVerificationError struct {
+	Reason string
+}
+
+func (e *DeviceVerificationError) Error() string {
+	return "device verification failed: " + e.Reason
+}
+
+// DeviceVerifiable is implemented by JWSAppTransactionDecodedPayload and
+// JWSTransactionDecodedPayload.
+type DeviceVerifiable interface {
+	deviceVerification() (verification, nonce string)
+}
+
+func (p JWSAppTransactionDecodedPayload) deviceVerification() (string, string) {
+	return p.DeviceVerification, p.DeviceVerificationNonce
+}
+
+func (p JWSTransactionDecodedPayload) deviceVerification() (string, string) {
+	return p.DeviceVerification, p.DeviceVerificationNonce
+}
+
+// DeviceVerificationValue computes the deviceVerification value Apple signs
+// for a device: the base64 encoded SHA-384 hash of the lowercased nonce
+// followed by the lowercased device identifier, i.e. identifierForVendor.
+func DeviceVerificationValue(deviceID, nonce string) (string, error) {
+	if !isUUID(deviceID) {
+		return "", fmt.Errorf("device ID %q is not a UUID", deviceID)
+	}
+	if !isUUID(nonce) {
+		return "", fmt.Errorf("nonce %q is not a UUID", nonce)
+	}
+	sum := sha512.Sum384([]byte(strings.ToLower(nonce) + strings.ToLower(deviceID)))
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// VerifyDevice checks that payload, as sent by an app, was issued to the
+// device with identifierForVendor deviceID for the given nonce. It returns a
+// *DeviceVerificationError when it was not.
+func VerifyDevice(payload DeviceVerifiable, deviceID, nonce string) error {
+	verification, payloadNonce := payload.deviceVerification()
+	if verification == "" {
+		return &DeviceVerificationError{Reason: "payload has no deviceVerification"}
+	}
+	if !strings.EqualFold(nonce, payloadNonce) {
+		return &DeviceVerificationError{Reason: "nonce does not match deviceVerificationNonce"}
+	}
+	expected, err := DeviceVerificationValue(deviceID, nonce)
+	if err != nil {
+		return &DeviceVerificationError{Reason: err.Error()}
+	}
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(verification)) != 1 {
+		return &DeviceVerificationError{Reason: "deviceVerification does not match device"}
+	}
+	return nil
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
+}
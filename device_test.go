@@ -0,0 +1,59 @@
+package appstore
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	testDeviceID = "A1B2C3D4-E5F6-4789-ABCD-0123456789AB"
+	testNonce    = "9E0E5F2B-6D44-4B1D-A0C1-7B0C4F0D1E2A"
+	// base64(SHA-384(lowercase(testNonce) + lowercase(testDeviceID)))
+	testDeviceVerification = "efajvrIW9e4U4qGq3t4i8zmoHTP2+WkYZRbjRh7YbbgGDqQAbbuf64P3SzlXaRuS"
+)
+
+func TestDeviceVerificationValue(t *testing.T) {
+	got, err := DeviceVerificationValue(testDeviceID, testNonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != testDeviceVerification {
+		t.Errorf("got %s, want %s", got, testDeviceVerification)
+	}
+	if _, err := DeviceVerificationValue("not-a-uuid", testNonce); err == nil {
+		t.Error("invalid device ID: got nil error")
+	}
+}
+
+func TestVerifyDevice(t *testing.T) {
+	p := JWSAppTransactionDecodedPayload{
+		DeviceVerification:      testDeviceVerification,
+		DeviceVerificationNonce: "9e0e5f2b-6d44-4b1d-a0c1-7b0c4f0d1e2a",
+	}
+	if err := VerifyDevice(p, testDeviceID, testNonce); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		deviceID, nonce string
+	}{
+		{"mismatched nonce", testDeviceID, "1D8C4B7A-2F4E-4A1C-9E3B-5C6D7E8F9A0B"},
+		{"mismatched device", "0F1E2D3C-4B5A-4978-8695-A4B3C2D1E0F9", testNonce},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyDevice(p, tt.deviceID, tt.nonce)
+			var verr *DeviceVerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want *DeviceVerificationError", err)
+			}
+		})
+	}
+
+	var unverified JWSTransactionDecodedPayload
+	var verr *DeviceVerificationError
+	if err := VerifyDevice(unverified, testDeviceID, testNonce); !errors.As(err, &verr) {
+		t.Fatalf("payload without deviceVerification: got %v, want *DeviceVerificationError", err)
+	}
+}
@@ -0,0 +1,339 @@
+package appstore
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/erictse/appstore-go/subscription"
+	"github.com/erictse/appstore-go/transaction"
+)
+
+// Integer codes keep values this package does not know about, which print as
+// e.g. OfferType(9). String codes keep the value Apple sent as is.
+
+func enumString[T ~int32](v T, names map[T]string) string {
+	if name, ok := names[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("%T(%d)", v, int32(v))
+}
+
+type AutoRenewStatus int32
+
+const (
+	AutoRenewStatusOff AutoRenewStatus = 0
+	AutoRenewStatusOn  AutoRenewStatus = 1
+)
+
+var autoRenewStatusNames = map[AutoRenewStatus]string{
+	AutoRenewStatusOff: "OFF",
+	AutoRenewStatusOn:  "ON",
+}
+
+func (s AutoRenewStatus) String() string {
+	return enumString(s, autoRenewStatusNames)
+}
+
+func (s AutoRenewStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(s))
+}
+
+func (s *AutoRenewStatus) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(s))
+}
+
+type ExpirationIntent int32
+
+const (
+	ExpirationIntentCustomerCancelled                    ExpirationIntent = 1
+	ExpirationIntentBillingError                         ExpirationIntent = 2
+	ExpirationIntentCustomerDidNotConsentToPriceIncrease ExpirationIntent = 3
+	ExpirationIntentProductNotAvailable                  ExpirationIntent = 4
+	ExpirationIntentOther                                ExpirationIntent = 5
+)
+
+var expirationIntentNames = map[ExpirationIntent]string{
+	ExpirationIntentCustomerCancelled:                    "CUSTOMER_CANCELLED",
+	ExpirationIntentBillingError:                         "BILLING_ERROR",
+	ExpirationIntentCustomerDidNotConsentToPriceIncrease: "CUSTOMER_DID_NOT_CONSENT_TO_PRICE_INCREASE",
+	ExpirationIntentProductNotAvailable:                  "PRODUCT_NOT_AVAILABLE",
+	ExpirationIntentOther:                                "OTHER",
+}
+
+func (i ExpirationIntent) String() string {
+	return enumString(i, expirationIntentNames)
+}
+
+func (i ExpirationIntent) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(i))
+}
+
+func (i *ExpirationIntent) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(i))
+}
+
+type OfferType int32
+
+const (
+	OfferTypeIntroductory OfferType = 1
+	OfferTypePromotional  OfferType = 2
+	OfferTypeOfferCode    OfferType = 3
+	OfferTypeWinBack      OfferType = 4
+)
+
+var offerTypeNames = map[OfferType]string{
+	OfferTypeIntroductory: "INTRODUCTORY_OFFER",
+	OfferTypePromotional:  "PROMOTIONAL_OFFER",
+	OfferTypeOfferCode:    "SUBSCRIPTION_OFFER_CODE",
+	OfferTypeWinBack:      "WIN_BACK_OFFER",
+}
+
+func (t OfferType) String() string {
+	return enumString(t, offerTypeNames)
+}
+
+func (t OfferType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(t))
+}
+
+func (t *OfferType) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(t))
+}
+
+type PriceIncreaseStatus int32
+
+const (
+	PriceIncreaseStatusCustomerHasNotResponded PriceIncreaseStatus = 0
+	PriceIncreaseStatusCustomerConsented       PriceIncreaseStatus = 1
+)
+
+var priceIncreaseStatusNames = map[PriceIncreaseStatus]string{
+	PriceIncreaseStatusCustomerHasNotResponded: "CUSTOMER_HAS_NOT_RESPONDED",
+	PriceIncreaseStatusCustomerConsented:       "CUSTOMER_CONSENTED_OR_WAS_NOTIFIED_WITHOUT_NEEDING_CONSENT",
+}
+
+func (s PriceIncreaseStatus) String() string {
+	return enumString(s, priceIncreaseStatusNames)
+}
+
+func (s PriceIncreaseStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(s))
+}
+
+func (s *PriceIncreaseStatus) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(s))
+}
+
+type RevocationReason int32
+
+const (
+	RevocationReasonOther    RevocationReason = 0
+	RevocationReasonAppIssue RevocationReason = 1
+)
+
+var revocationReasonNames = map[RevocationReason]string{
+	RevocationReasonOther:    "OTHER",
+	RevocationReasonAppIssue: "REFUNDED_DUE_TO_ISSUE",
+}
+
+func (r RevocationReason) String() string {
+	return enumString(r, revocationReasonNames)
+}
+
+func (r RevocationReason) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(r))
+}
+
+func (r *RevocationReason) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(r))
+}
+
+// SubscriptionStatus is subscription.Status, so statuses in responses can be
+// passed to subscription.WithStatus.
+type SubscriptionStatus = subscription.Status
+
+const (
+	SubscriptionStatusActive       = subscription.Active
+	SubscriptionStatusExpired      = subscription.Expired
+	SubscriptionStatusBillingRetry = subscription.BillingRetry
+	SubscriptionStatusGracePeriod  = subscription.GracePeriod
+	SubscriptionStatusRevoked      = subscription.Revoked
+)
+
+func (e Environment) String() string {
+	return string(e)
+}
+
+func (e Environment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(e))
+}
+
+func (e *Environment) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(e))
+}
+
+// InAppOwnershipType is transaction.InAppOwnershipType, so ownership types in
+// payloads can be passed to transaction.WithInAppOwnershipType.
+type InAppOwnershipType = transaction.InAppOwnershipType
+
+const (
+	InAppOwnershipTypeFamilyShared = transaction.FamilyShared
+	InAppOwnershipTypePurchased    = transaction.Purchased
+)
+
+type OfferDiscountType string
+
+const (
+	OfferDiscountTypeFreeTrial  OfferDiscountType = "FREE_TRIAL"
+	OfferDiscountTypePayAsYouGo OfferDiscountType = "PAY_AS_YOU_GO"
+	OfferDiscountTypePayUpFront OfferDiscountType = "PAY_UP_FRONT"
+	OfferDiscountTypeOneTime    OfferDiscountType = "ONE_TIME"
+)
+
+func (t OfferDiscountType) String() string {
+	return string(t)
+}
+
+func (t OfferDiscountType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func (t *OfferDiscountType) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(t))
+}
+
+type OriginalPlatform string
+
+const (
+	OriginalPlatformIOS      OriginalPlatform = "iOS"
+	OriginalPlatformMacOS    OriginalPlatform = "macOS"
+	OriginalPlatformTVOS     OriginalPlatform = "tvOS"
+	OriginalPlatformVisionOS OriginalPlatform = "visionOS"
+)
+
+func (p OriginalPlatform) String() string {
+	return string(p)
+}
+
+func (p OriginalPlatform) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(p))
+}
+
+func (p *OriginalPlatform) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(p))
+}
+
+type ProductType string
+
+const (
+	ProductTypeAutoRenewable ProductType = "Auto-Renewable Subscription"
+	ProductTypeNonConsumable ProductType = "Non-Consumable"
+	ProductTypeConsumable    ProductType = "Consumable"
+	ProductTypeNonRenewing   ProductType = "Non-Renewing Subscription"
+)
+
+func (t ProductType) String() string {
+	return string(t)
+}
+
+func (t ProductType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func (t *ProductType) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(t))
+}
+
+type TransactionReason string
+
+const (
+	TransactionReasonPurchase TransactionReason = "PURCHASE"
+	TransactionReasonRenewal  TransactionReason = "RENEWAL"
+)
+
+func (r TransactionReason) String() string {
+	return string(r)
+}
+
+func (r TransactionReason) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(r))
+}
+
+func (r *TransactionReason) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(r))
+}
+
+type NotificationType string
+
+const (
+	NotificationTypeConsumptionRequest     NotificationType = "CONSUMPTION_REQUEST"
+	NotificationTypeDidChangeRenewalPref   NotificationType = "DID_CHANGE_RENEWAL_PREF"
+	NotificationTypeDidChangeRenewalStatus NotificationType = "DID_CHANGE_RENEWAL_STATUS"
+	NotificationTypeDidFailToRenew         NotificationType = "DID_FAIL_TO_RENEW"
+	NotificationTypeDidRenew               NotificationType = "DID_RENEW"
+	NotificationTypeExpired                NotificationType = "EXPIRED"
+	NotificationTypeExternalPurchaseToken  NotificationType = "EXTERNAL_PURCHASE_TOKEN"
+	NotificationTypeGracePeriodExpired     NotificationType = "GRACE_PERIOD_EXPIRED"
+	NotificationTypeMetadataUpdate         NotificationType = "METADATA_UPDATE"
+	NotificationTypeMigration              NotificationType = "MIGRATION"
+	NotificationTypeOfferRedeemed          NotificationType = "OFFER_REDEEMED"
+	NotificationTypeOneTimeCharge          NotificationType = "ONE_TIME_CHARGE"
+	NotificationTypePriceChange            NotificationType = "PRICE_CHANGE"
+	NotificationTypePriceIncrease          NotificationType = "PRICE_INCREASE"
+	NotificationTypeRefund                 NotificationType = "REFUND"
+	NotificationTypeRefundDeclined         NotificationType = "REFUND_DECLINED"
+	NotificationTypeRefundReversed         NotificationType = "REFUND_REVERSED"
+	NotificationTypeRenewalExtended        NotificationType = "RENEWAL_EXTENDED"
+	NotificationTypeRenewalExtension       NotificationType = "RENEWAL_EXTENSION"
+	NotificationTypeRescindConsent         NotificationType = "RESCIND_CONSENT"
+	NotificationTypeRevoke                 NotificationType = "REVOKE"
+	NotificationTypeSubscribed             NotificationType = "SUBSCRIBED"
+	NotificationTypeTest                   NotificationType = "TEST"
+)
+
+func (t NotificationType) String() string {
+	return string(t)
+}
+
+func (t NotificationType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func (t *NotificationType) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(t))
+}
+
+type Subtype string
+
+const (
+	SubtypeAccepted            Subtype = "ACCEPTED"
+	SubtypeActiveTokenReminder Subtype = "ACTIVE_TOKEN_REMINDER"
+	SubtypeAutoRenewDisabled   Subtype = "AUTO_RENEW_DISABLED"
+	SubtypeAutoRenewEnabled    Subtype = "AUTO_RENEW_ENABLED"
+	SubtypeBillingRecovery     Subtype = "BILLING_RECOVERY"
+	SubtypeBillingRetry        Subtype = "BILLING_RETRY"
+	SubtypeDowngrade           Subtype = "DOWNGRADE"
+	SubtypeFailure             Subtype = "FAILURE"
+	SubtypeGracePeriod         Subtype = "GRACE_PERIOD"
+	SubtypeInitialBuy          Subtype = "INITIAL_BUY"
+	SubtypePending             Subtype = "PENDING"
+	SubtypePriceIncrease       Subtype = "PRICE_INCREASE"
+	SubtypeProductNotForSale   Subtype = "PRODUCT_NOT_FOR_SALE"
+	SubtypeResubscribe         Subtype = "RESUBSCRIBE"
+	SubtypeSummary             Subtype = "SUMMARY"
+	SubtypeUnreported          Subtype = "UNREPORTED"
+	SubtypeUpgrade             Subtype = "UPGRADE"
+	SubtypeVoluntary           Subtype = "VOLUNTARY"
+)
+
+func (s Subtype) String() string {
+	return string(s)
+}
+
+func (s Subtype) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(s))
+}
+
+func (s *Subtype) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(s))
+}
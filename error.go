@@ -10,3 +10,34 @@ type Error struct {
 func (e Error) Error() string {
 	return fmt.Sprintf("code: %d message: %s", e.ErrorCode, e.ErrorMessage)
 }
+
+// Is reports whether target is an Error with the same error code, so
+// errors.Is(err, ErrOriginalTransactionIDNotFound) matches API responses.
+func (e Error) Is(target error) bool {
+	t, ok := target.(Error)
+	return ok && t.ErrorCode == e.ErrorCode
+}
+
+// Errors returned by the App Store Server API, matched by error code. See
+// https://developer.apple.com/documentation/appstoreserverapi/error_codes
+var (
+	ErrInvalidOriginalTransactionID          = Error{ErrorCode: 4000008, ErrorMessage: "Invalid original transaction id."}
+	ErrInvalidTransactionTypeNotSupported    = Error{ErrorCode: 4000047, ErrorMessage: "Invalid request. The transaction type is not supported."}
+	ErrInvalidAppAccountTokenUUID            = Error{ErrorCode: 4000183, ErrorMessage: "Invalid request. The app account token field must be a valid UUID."}
+	ErrTransactionIDNotOriginalTransactionID = Error{ErrorCode: 4000187, ErrorMessage: "Invalid request. The transaction ID provided is not an original transaction ID."}
+	ErrOriginalTransactionIDNotFound         = Error{ErrorCode: 4040005, ErrorMessage: "Original transaction id not found."}
+	ErrTransactionIDNotFound                 = Error{ErrorCode: 4040010, ErrorMessage: "Transaction id not found."}
+	ErrRateLimitExceeded                     = Error{ErrorCode: 4290000, ErrorMessage: "Rate limit exceeded."}
+)
+
+// ClaimMismatchError reports a signed payload that belongs to a different
+// app or environment than the client or verifier is configured for.
+type ClaimMismatchError struct {
+	Claim    string
+	Expected string
+	Actual   string
+}
+
+func (e *ClaimMismatchError) Error() string {
+	return fmt.Sprintf("%s %q does not match expected %q", e.Claim, e.Actual, e.Expected)
+}
@@ -3,3 +3,5 @@ module github.com/erictse/appstore-go
 go 1.20
 
 require github.com/golang-jwt/jwt/v4 v4.5.0
+
+require golang.org/x/crypto v0.21.0
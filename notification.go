@@ -2,18 +2,28 @@ package appstore
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
 type ResponseBodyV2DecodedPayload struct {
-	NotificationType string                               `json:"notificationType"`
-	Subtype          string                               `json:"subtype"`
+	NotificationType NotificationType                     `json:"notificationType"`
+	Subtype          Subtype                              `json:"subtype"`
 	Data             *ResponseBodyV2DecodedPayloadData    `json:"data,omitempty"`
 	Summary          *ResponseBodyV2DecodedPayloadSummary `json:"summary,omitempty"`
 	Version          string                               `json:"version"`
 	NotificationUUID string                               `json:"notificationUUID"`
 
+	// Notifications about external purchase tokens and app metadata carry
+	// these instead of data or summary, see Variant.
+	ExternalPurchaseToken *ExternalPurchaseToken               `json:"externalPurchaseToken,omitempty"`
+	AppData               *ResponseBodyV2DecodedPayloadAppData `json:"appData,omitempty"`
+
 	// SignedDate *time.Time
 	SignedDate Millistamp `json:"signedDate"`
 }
@@ -22,6 +32,69 @@ func (p ResponseBodyV2DecodedPayload) Valid() error {
 	return nil
 }
 
+func (p ResponseBodyV2DecodedPayload) appClaims() (string, int64, string) {
+	switch {
+	case p.Data != nil:
+		return p.Data.BundleId, p.Data.AppAppleId, p.Data.Environment
+	case p.Summary != nil:
+		appAppleID, _ := strconv.ParseInt(p.Summary.AppAppleId, 10, 64)
+		return p.Summary.BundleId, appAppleID, p.Summary.Environment
+	case p.ExternalPurchaseToken != nil:
+		return p.ExternalPurchaseToken.BundleId, p.ExternalPurchaseToken.AppAppleId,
+			string(p.ExternalPurchaseToken.Environment())
+	case p.AppData != nil:
+		return p.AppData.BundleId, p.AppData.AppAppleId, p.AppData.Environment
+	}
+	return "", 0, ""
+}
+
+func (p ResponseBodyV2DecodedPayload) signedDate() time.Time {
+	return p.SignedDate.Time
+}
+
+// NotificationVariant tells which of data, summary, externalPurchaseToken
+// and appData a notification carries. Exactly one is present, except in
+// notifications this package cannot decode.
+type NotificationVariant int
+
+const (
+	NotificationVariantUnknown NotificationVariant = iota
+	NotificationVariantData
+	NotificationVariantSummary
+	NotificationVariantExternalPurchaseToken
+	NotificationVariantAppData
+)
+
+var notificationVariantNames = map[NotificationVariant]string{
+	NotificationVariantUnknown:               "unknown",
+	NotificationVariantData:                  "data",
+	NotificationVariantSummary:               "summary",
+	NotificationVariantExternalPurchaseToken: "externalPurchaseToken",
+	NotificationVariantAppData:               "appData",
+}
+
+func (v NotificationVariant) String() string {
+	if name, ok := notificationVariantNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("NotificationVariant(%d)", int(v))
+}
+
+// Variant reports which of the notification's data fields is set.
+func (p ResponseBodyV2DecodedPayload) Variant() NotificationVariant {
+	switch {
+	case p.Data != nil:
+		return NotificationVariantData
+	case p.Summary != nil:
+		return NotificationVariantSummary
+	case p.ExternalPurchaseToken != nil:
+		return NotificationVariantExternalPurchaseToken
+	case p.AppData != nil:
+		return NotificationVariantAppData
+	}
+	return NotificationVariantUnknown
+}
+
 type ResponseBodyV2DecodedPayloadData struct {
 	AppAppleId            int64   `json:"appAppleId"`
 	BundleId              string  `json:"bundleId"`
@@ -38,11 +111,21 @@ func (p *ResponseBodyV2DecodedPayloadData) DecodeJWS(keyFunc jwt.Keyfunc, data [
 	if err := json.Unmarshal(data, &p); err != nil {
 		return err
 	}
-	if err := p.SignedRenewalInfo.Decode(keyFunc, &p.RenewalInfo); err != nil {
-		return err
+	return p.decodeSigned(keyFunc)
+}
+
+// decodeSigned decodes the signed renewal and transaction info, either of
+// which may be missing, e.g. there is no renewal info for consumables.
+func (p *ResponseBodyV2DecodedPayloadData) decodeSigned(keyFunc jwt.Keyfunc) error {
+	if p.SignedRenewalInfo != "" {
+		if err := p.SignedRenewalInfo.Decode(keyFunc, &p.RenewalInfo); err != nil {
+			return err
+		}
 	}
-	if err := p.SignedTransactionInfo.Decode(keyFunc, &p.TransactionInfo); err != nil {
-		return err
+	if p.SignedTransactionInfo != "" {
+		if err := p.SignedTransactionInfo.Decode(keyFunc, &p.TransactionInfo); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -58,20 +141,65 @@ type ResponseBodyV2DecodedPayloadSummary struct {
 	SucceededCount         int64    `json:"succeededCount"`
 }
 
+type ExternalPurchaseToken struct {
+	ExternalPurchaseId string `json:"externalPurchaseId"`
+	AppAppleId         int64  `json:"appAppleId"`
+	BundleId           string `json:"bundleId"`
+
+	TokenCreationDate *Millistamp `json:"tokenCreationDate"`
+}
+
+// Environment tells the environment the token was created in, which Apple
+// marks by prefixing sandbox externalPurchaseId values with SANDBOX.
+func (t ExternalPurchaseToken) Environment() Environment {
+	if strings.HasPrefix(t.ExternalPurchaseId, "SANDBOX") {
+		return EnvironmentSandbox
+	}
+	return EnvironmentProduction
+}
+
+type ResponseBodyV2DecodedPayloadAppData struct {
+	AppAppleId               int64   `json:"appAppleId"`
+	BundleId                 string  `json:"bundleId"`
+	Environment              string  `json:"environment"`
+	SignedAppTransactionInfo JWSData `json:"signedAppTransactionInfo"`
+
+	AppTransaction JWSAppTransactionDecodedPayload
+}
+
+func (p *ResponseBodyV2DecodedPayloadAppData) decodeSigned(keyFunc jwt.Keyfunc) error {
+	if p.SignedAppTransactionInfo != "" {
+		if err := p.SignedAppTransactionInfo.Decode(keyFunc, &p.AppTransaction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type NotificationHistoryResponse struct {
 	NotificationHistory []*NotificationHistoryResponseItem `json:"notificationHistory"`
 	HasMore             bool                               `json:"hasMore"`
 	PaginationToken     string                             `json:"paginationToken"`
 }
 
+// DecodeJWS decodes every notification it can. Notifications that fail to
+// decode have their Err set and their errors are returned joined.
 func (p *NotificationHistoryResponse) DecodeJWS(keyFunc jwt.Keyfunc, data []byte) error {
 	if err := json.Unmarshal(data, &p); err != nil {
 		return err
 	}
+	var errs []error
 	for _, n := range p.NotificationHistory {
-		if err := n.SignedPayload.Decode(keyFunc, &n.Payload); err != nil {
-			return err
+		payload, err := decodeNotification(keyFunc, n.SignedPayload)
+		if err != nil {
+			n.Err = err
+			errs = append(errs, err)
+			continue
 		}
+		n.Payload = *payload
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not decode all notifications: %w", errors.Join(errs...))
 	}
 	return nil
 }
@@ -81,4 +209,6 @@ type NotificationHistoryResponseItem struct {
 	SignedPayload          JWSData `json:"signedPayload"`
 
 	Payload ResponseBodyV2DecodedPayload
+	// Err is why SignedPayload could not be decoded, if it could not.
+	Err error `json:"-"`
 }
@@ -0,0 +1,92 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const maxNotificationBodySize = 1 << 20
+
+// NotificationFunc processes a verified App Store Server Notification. An
+// error makes the handler respond with a 5xx status so Apple retries.
+type NotificationFunc func(ctx context.Context, p *ResponseBodyV2DecodedPayload) error
+
+// ResponseBodyV2 is the body Apple posts to the App Store Server
+// Notifications V2 URL.
+type ResponseBodyV2 struct {
+	SignedPayload JWSData `json:"signedPayload"`
+}
+
+// NotificationHandler is an http.Handler for App Store Server Notifications
+// V2. It verifies the signed payload, including the transaction and renewal
+// info nested in it, before calling its NotificationFunc.
+type NotificationHandler struct {
+	keyFunc jwt.Keyfunc
+	fn      NotificationFunc
+}
+
+func NewNotificationHandler(c *Client, fn NotificationFunc) *NotificationHandler {
+	return &NotificationHandler{keyFunc: c.keyFunc, fn: fn}
+}
+
+func (h *NotificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBodySize))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	var body ResponseBodyV2
+	if err := json.Unmarshal(data, &body); err != nil || body.SignedPayload == "" {
+		http.Error(w, "body has no signedPayload", http.StatusBadRequest)
+		return
+	}
+	p, err := decodeNotification(h.keyFunc, body.SignedPayload)
+	if err != nil {
+		http.Error(w, "could not verify signedPayload", http.StatusBadRequest)
+		return
+	}
+	ctx := context.WithValue(r.Context(), rawNotificationKey{}, data)
+	if err := h.fn(ctx, p); err != nil {
+		http.Error(w, "could not process notification", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+type rawNotificationKey struct{}
+
+// RawNotificationBody returns the body Apple posted, as received, to a
+// NotificationFunc called by NotificationHandler.
+func RawNotificationBody(ctx context.Context) ([]byte, bool) {
+	data, ok := ctx.Value(rawNotificationKey{}).([]byte)
+	return data, ok
+}
+
+// decodeNotification verifies a notification's signed payload and the
+// signed data nested in it.
+func decodeNotification(keyFunc jwt.Keyfunc, signedPayload JWSData) (*ResponseBodyV2DecodedPayload, error) {
+	var p ResponseBodyV2DecodedPayload
+	if err := signedPayload.Decode(keyFunc, &p); err != nil {
+		return nil, err
+	}
+	if p.Data != nil {
+		if err := p.Data.decodeSigned(keyFunc); err != nil {
+			return nil, err
+		}
+	}
+	if p.AppData != nil {
+		if err := p.AppData.decodeSigned(keyFunc); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
@@ -0,0 +1,100 @@
+package appstore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// newTestClient creates a sandbox Client that trusts the root of chain.
+func newTestClient(t *testing.T, chain testChain) *Client {
+	t.Helper()
+	optCerts, err := WithAppleRootCerts(chain.root.cert.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(optCerts, WithSandbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// testNotification returns notification claims in Apple's wire format.
+func testNotification() jwt.MapClaims {
+	return jwt.MapClaims{
+		"notificationType": string(NotificationTypeTest),
+		"notificationUUID": "3838df56-31ab-4e2b-9535-e6e9377c4c77",
+		"version":          "2.0",
+		"signedDate":       time.Now().UnixMilli(),
+		"data": map[string]any{
+			"bundleId":    testBundleID,
+			"environment": string(EnvironmentSandbox),
+		},
+	}
+}
+
+func notificationBody(signedPayload string) string {
+	return `{"signedPayload":"` + signedPayload + `"}`
+}
+
+func serveNotification(h http.Handler, method, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, "/appstore/notifications", strings.NewReader(body)))
+	return w
+}
+
+func TestNotificationHandler(t *testing.T) {
+	chain := newTestChain(t, "")
+	forger := newTestChain(t, "")
+	c := newTestClient(t, chain)
+	valid := notificationBody(signTestJWS(t, chain.leaf.key, chain.x5c(), testNotification()))
+	forged := notificationBody(signTestJWS(t, forger.leaf.key, forger.x5c(), testNotification()))
+
+	var got *ResponseBodyV2DecodedPayload
+	ok := NewNotificationHandler(c, func(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+		got = p
+		return nil
+	})
+	failing := NewNotificationHandler(c, func(context.Context, *ResponseBodyV2DecodedPayload) error {
+		return errors.New("database unavailable")
+	})
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		method  string
+		body    string
+		want    int
+	}{
+		{"valid", ok, http.MethodPost, valid, http.StatusOK},
+		{"forged", ok, http.MethodPost, forged, http.StatusBadRequest},
+		{"no signedPayload", ok, http.MethodPost, `{}`, http.StatusBadRequest},
+		{"callback error", failing, http.MethodPost, valid, http.StatusInternalServerError},
+		{"GET", ok, http.MethodGet, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			w := serveNotification(tt.handler, tt.method, tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+			if tt.want != http.StatusOK {
+				if got != nil {
+					t.Errorf("callback ran for %s", tt.name)
+				}
+				return
+			}
+			if got == nil || got.NotificationType != NotificationTypeTest {
+				t.Errorf("got notification %+v", got)
+			}
+		})
+	}
+}
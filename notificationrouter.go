@@ -0,0 +1,152 @@
+package appstore
+
+import (
+	"context"
+)
+
+// AnySubtype registers a handler for every subtype of a notification type,
+// including notifications without a subtype.
+const AnySubtype Subtype = "*"
+
+type NotificationMiddleware func(NotificationFunc) NotificationFunc
+
+// RouteReport tells which handler a NotificationRouter ran for a
+// notification. Route is the matched pattern, e.g. "SUBSCRIBED/INITIAL_BUY",
+// "DID_RENEW/*" or "fallback", and empty when nothing matched.
+type RouteReport struct {
+	NotificationUUID string
+	NotificationType NotificationType
+	Subtype          Subtype
+	Route            string
+	Err              error
+}
+
+func (r RouteReport) Handled() bool {
+	return r.Route != ""
+}
+
+type routeKey struct {
+	notificationType NotificationType
+	subtype          Subtype
+}
+
+// NotificationRouter dispatches decoded notifications to handlers registered
+// by notification type and subtype. Register handlers and middleware before
+// dispatching; dispatching is safe for concurrent use.
+type NotificationRouter struct {
+	routes     map[routeKey]NotificationFunc
+	fallback   NotificationFunc
+	middleware []NotificationMiddleware
+	onReport   func(context.Context, RouteReport)
+}
+
+func NewNotificationRouter() *NotificationRouter {
+	return &NotificationRouter{routes: make(map[routeKey]NotificationFunc)}
+}
+
+// Handle registers fn for notificationType and subtype. Use AnySubtype to
+// match every subtype and the empty Subtype to match notifications without
+// one. A handler for an exact subtype wins over one for AnySubtype.
+func (r *NotificationRouter) Handle(notificationType NotificationType, subtype Subtype, fn NotificationFunc) {
+	r.routes[routeKey{notificationType, subtype}] = fn
+}
+
+// Fallback registers fn for notifications no other handler matches.
+func (r *NotificationRouter) Fallback(fn NotificationFunc) {
+	r.fallback = fn
+}
+
+// Use adds middleware around every handler. The first added runs outermost.
+func (r *NotificationRouter) Use(mw ...NotificationMiddleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// OnReport calls fn after every dispatch, e.g. for logging or metrics.
+func (r *NotificationRouter) OnReport(fn func(context.Context, RouteReport)) {
+	r.onReport = fn
+}
+
+func (r *NotificationRouter) OnConsumptionRequest(fn NotificationFunc) {
+	r.Handle(NotificationTypeConsumptionRequest, AnySubtype, fn)
+}
+
+func (r *NotificationRouter) OnDidChangeRenewalPref(subtype Subtype, fn NotificationFunc) {
+	r.Handle(NotificationTypeDidChangeRenewalPref, subtype, fn)
+}
+
+func (r *NotificationRouter) OnDidChangeRenewalStatus(subtype Subtype, fn NotificationFunc) {
+	r.Handle(NotificationTypeDidChangeRenewalStatus, subtype, fn)
+}
+
+func (r *NotificationRouter) OnDidFailToRenew(subtype Subtype, fn NotificationFunc) {
+	r.Handle(NotificationTypeDidFailToRenew, subtype, fn)
+}
+
+func (r *NotificationRouter) OnDidRenew(fn NotificationFunc) {
+	r.Handle(NotificationTypeDidRenew, AnySubtype, fn)
+}
+
+func (r *NotificationRouter) OnExpired(subtype Subtype, fn NotificationFunc) {
+	r.Handle(NotificationTypeExpired, subtype, fn)
+}
+
+func (r *NotificationRouter) OnGracePeriodExpired(fn NotificationFunc) {
+	r.Handle(NotificationTypeGracePeriodExpired, AnySubtype, fn)
+}
+
+func (r *NotificationRouter) OnRefund(fn NotificationFunc) {
+	r.Handle(NotificationTypeRefund, AnySubtype, fn)
+}
+
+func (r *NotificationRouter) OnRevoke(fn NotificationFunc) {
+	r.Handle(NotificationTypeRevoke, AnySubtype, fn)
+}
+
+func (r *NotificationRouter) OnSubscribed(subtype Subtype, fn NotificationFunc) {
+	r.Handle(NotificationTypeSubscribed, subtype, fn)
+}
+
+func (r *NotificationRouter) OnTest(fn NotificationFunc) {
+	r.Handle(NotificationTypeTest, AnySubtype, fn)
+}
+
+// Dispatch runs the handler matching p and reports which one ran. It is not
+// an error for no handler to match.
+func (r *NotificationRouter) Dispatch(ctx context.Context, p *ResponseBodyV2DecodedPayload) (RouteReport, error) {
+	report := RouteReport{
+		NotificationUUID: p.NotificationUUID,
+		NotificationType: p.NotificationType,
+		Subtype:          p.Subtype,
+	}
+	fn, route := r.match(p.NotificationType, p.Subtype)
+	if fn != nil {
+		for i := len(r.middleware) - 1; i >= 0; i-- {
+			fn = r.middleware[i](fn)
+		}
+		report.Route = route
+		report.Err = fn(ctx, p)
+	}
+	if r.onReport != nil {
+		r.onReport(ctx, report)
+	}
+	return report, report.Err
+}
+
+// Process is Dispatch as a NotificationFunc, e.g. for NewNotificationHandler.
+func (r *NotificationRouter) Process(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+	_, err := r.Dispatch(ctx, p)
+	return err
+}
+
+func (r *NotificationRouter) match(notificationType NotificationType, subtype Subtype) (NotificationFunc, string) {
+	if fn, ok := r.routes[routeKey{notificationType, subtype}]; ok {
+		return fn, string(notificationType) + "/" + string(subtype)
+	}
+	if fn, ok := r.routes[routeKey{notificationType, AnySubtype}]; ok {
+		return fn, string(notificationType) + "/" + string(AnySubtype)
+	}
+	if r.fallback != nil {
+		return r.fallback, "fallback"
+	}
+	return nil, ""
+}
@@ -0,0 +1,306 @@
+package appstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const environmentV1Production = "PROD"
+
+// StringMillistamp is a Unix time in milliseconds sent as a JSON string, as
+// in App Store Server Notifications V1. An empty string or null is the zero
+// time.
+type StringMillistamp struct{ time.Time }
+
+func (m *StringMillistamp) UnmarshalJSON(data []byte) error {
+	trimmed := bytes.Trim(data, `"`)
+	if len(trimmed) == 0 || string(trimmed) == "null" {
+		return nil
+	}
+	val, err := strconv.ParseInt(string(trimmed), 10, 64)
+	if err != nil {
+		return err
+	}
+	if val > 0 {
+		m.Time = time.UnixMilli(val)
+	}
+	return nil
+}
+
+// StringBool is a flag sent as "true"/"false" or "1"/"0", as in App Store
+// Server Notifications V1.
+type StringBool bool
+
+func (b *StringBool) UnmarshalJSON(data []byte) error {
+	switch string(bytes.Trim(data, `"`)) {
+	case "true", "1":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// NotificationV1 is the unsigned body of an App Store Server Notification V1.
+type NotificationV1 struct {
+	AutoRenewAdamID           string           `json:"auto_renew_adam_id"`
+	AutoRenewProductID        string           `json:"auto_renew_product_id"`
+	AutoRenewStatus           StringBool       `json:"auto_renew_status"`
+	AutoRenewStatusChangeDate StringMillistamp `json:"auto_renew_status_change_date_ms"`
+	BundleID                  string           `json:"bid"`
+	BundleVersion             string           `json:"bvrs"`
+	Environment               string           `json:"environment"`
+	NotificationType          string           `json:"notification_type"`
+	Password                  string           `json:"password"`
+	UnifiedReceipt            UnifiedReceipt   `json:"unified_receipt"`
+}
+
+type UnifiedReceipt struct {
+	Environment        string               `json:"environment"`
+	LatestReceipt      string               `json:"latest_receipt"`
+	LatestReceiptInfo  []LatestReceiptInfo  `json:"latest_receipt_info"`
+	PendingRenewalInfo []PendingRenewalInfo `json:"pending_renewal_info"`
+	Status             int32                `json:"status"`
+}
+
+type LatestReceiptInfo struct {
+	AppAccountToken             string             `json:"app_account_token"`
+	CancellationDate            StringMillistamp   `json:"cancellation_date_ms"`
+	CancellationReason          string             `json:"cancellation_reason"`
+	ExpiresDate                 StringMillistamp   `json:"expires_date_ms"`
+	InAppOwnershipType          InAppOwnershipType `json:"in_app_ownership_type"`
+	IsInIntroOfferPeriod        StringBool         `json:"is_in_intro_offer_period"`
+	IsTrialPeriod               StringBool         `json:"is_trial_period"`
+	IsUpgraded                  StringBool         `json:"is_upgraded"`
+	OfferCodeRefName            string             `json:"offer_code_ref_name"`
+	OriginalPurchaseDate        StringMillistamp   `json:"original_purchase_date_ms"`
+	OriginalTransactionID       string             `json:"original_transaction_id"`
+	ProductID                   string             `json:"product_id"`
+	PromotionalOfferID          string             `json:"promotional_offer_id"`
+	PurchaseDate                StringMillistamp   `json:"purchase_date_ms"`
+	Quantity                    string             `json:"quantity"`
+	SubscriptionGroupIdentifier string             `json:"subscription_group_identifier"`
+	TransactionID               string             `json:"transaction_id"`
+	WebOrderLineItemID          string             `json:"web_order_line_item_id"`
+}
+
+type PendingRenewalInfo struct {
+	AutoRenewProductID     string           `json:"auto_renew_product_id"`
+	AutoRenewStatus        StringBool       `json:"auto_renew_status"`
+	ExpirationIntent       string           `json:"expiration_intent"`
+	GracePeriodExpiresDate StringMillistamp `json:"grace_period_expires_date_ms"`
+	IsInBillingRetryPeriod StringBool       `json:"is_in_billing_retry_period"`
+	OfferCodeRefName       string           `json:"offer_code_ref_name"`
+	OriginalTransactionID  string           `json:"original_transaction_id"`
+	PriceConsentStatus     string           `json:"price_consent_status"`
+	ProductID              string           `json:"product_id"`
+	PromotionalOfferID     string           `json:"promotional_offer_id"`
+}
+
+// NotificationV1Func processes an App Store Server Notification V1. An error
+// makes the handler respond with a 5xx status so Apple retries.
+type NotificationV1Func func(ctx context.Context, n *NotificationV1) error
+
+// NotificationV1Handler is an http.Handler for App Store Server Notifications
+// V1. It rejects notifications whose password is not the app's shared secret.
+type NotificationV1Handler struct {
+	sharedSecret string
+	fn           NotificationV1Func
+}
+
+// NewNotificationV1Handler creates a NotificationV1Handler passing
+// notifications to fn. With an empty sharedSecret every notification is
+// rejected.
+func NewNotificationV1Handler(sharedSecret string, fn NotificationV1Func) *NotificationV1Handler {
+	return &NotificationV1Handler{sharedSecret: sharedSecret, fn: fn}
+}
+
+func (h *NotificationV1Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBodySize))
+	if err != nil {
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+	var n NotificationV1
+	if err := json.Unmarshal(data, &n); err != nil {
+		http.Error(w, "could not parse notification", http.StatusBadRequest)
+		return
+	}
+	if h.sharedSecret == "" || subtle.ConstantTimeCompare([]byte(n.Password), []byte(h.sharedSecret)) != 1 {
+		http.Error(w, "invalid password", http.StatusUnauthorized)
+		return
+	}
+	if err := h.fn(r.Context(), &n); err != nil {
+		http.Error(w, "could not process notification", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// V1ToV2 adapts fn to process V1 notifications, see NotificationV1.ToV2.
+// Notification types without a V2 equivalent are acknowledged and dropped.
+func V1ToV2(fn NotificationFunc) NotificationV1Func {
+	return func(ctx context.Context, n *NotificationV1) error {
+		p, ok := n.ToV2()
+		if !ok {
+			return nil
+		}
+		return fn(ctx, p)
+	}
+}
+
+// V2Type maps the V1 notification type to its V2 notification type and
+// subtype, reporting false for types without an equivalent.
+func (n *NotificationV1) V2Type() (NotificationType, Subtype, bool) {
+	switch n.NotificationType {
+	case "INITIAL_BUY":
+		return NotificationTypeSubscribed, SubtypeInitialBuy, true
+	case "INTERACTIVE_RENEWAL":
+		return NotificationTypeSubscribed, SubtypeResubscribe, true
+	case "DID_RENEW":
+		return NotificationTypeDidRenew, "", true
+	case "DID_RECOVER", "RENEWAL":
+		return NotificationTypeDidRenew, SubtypeBillingRecovery, true
+	case "DID_CHANGE_RENEWAL_PREF":
+		return NotificationTypeDidChangeRenewalPref, "", true
+	case "DID_CHANGE_RENEWAL_STATUS":
+		if n.AutoRenewStatus {
+			return NotificationTypeDidChangeRenewalStatus, SubtypeAutoRenewEnabled, true
+		}
+		return NotificationTypeDidChangeRenewalStatus, SubtypeAutoRenewDisabled, true
+	case "DID_FAIL_TO_RENEW":
+		if t := n.latestTransaction(); t != nil {
+			if r := n.renewalInfo(t.OriginalTransactionID); r != nil && !r.GracePeriodExpiresDate.IsZero() {
+				return NotificationTypeDidFailToRenew, SubtypeGracePeriod, true
+			}
+		}
+		return NotificationTypeDidFailToRenew, "", true
+	case "CANCEL", "REFUND":
+		return NotificationTypeRefund, "", true
+	case "REVOKE":
+		return NotificationTypeRevoke, "", true
+	case "CONSUMPTION_REQUEST":
+		return NotificationTypeConsumptionRequest, "", true
+	case "PRICE_INCREASE_CONSENT":
+		return NotificationTypePriceIncrease, SubtypePending, true
+	}
+	return "", "", false
+}
+
+// ToV2 converts the notification to the V2 form so V1 and V2 notifications
+// can share one processing pipeline. The transaction and renewal info come
+// from the latest receipt info; V2 only fields are left empty. V1 has no
+// notification UUID, so Deduplicate passes converted notifications through
+// without deduplicating them.
+func (n *NotificationV1) ToV2() (*ResponseBodyV2DecodedPayload, bool) {
+	notificationType, subtype, ok := n.V2Type()
+	if !ok {
+		return nil, false
+	}
+	environment := EnvironmentSandbox
+	if n.Environment == environmentV1Production {
+		environment = EnvironmentProduction
+	}
+	data := &ResponseBodyV2DecodedPayloadData{
+		BundleId:      n.BundleID,
+		BundleVersion: n.BundleVersion,
+		Environment:   string(environment),
+	}
+	if t := n.latestTransaction(); t != nil {
+		data.TransactionInfo = t.toV2(n.BundleID, environment)
+		if r := n.renewalInfo(t.OriginalTransactionID); r != nil {
+			data.RenewalInfo = r.toV2(environment)
+		}
+	}
+	return &ResponseBodyV2DecodedPayload{
+		NotificationType: notificationType,
+		Subtype:          subtype,
+		Data:             data,
+	}, true
+}
+
+func (n *NotificationV1) latestTransaction() *LatestReceiptInfo {
+	var latest *LatestReceiptInfo
+	for i, t := range n.UnifiedReceipt.LatestReceiptInfo {
+		if latest == nil || t.PurchaseDate.After(latest.PurchaseDate.Time) {
+			latest = &n.UnifiedReceipt.LatestReceiptInfo[i]
+		}
+	}
+	return latest
+}
+
+func (n *NotificationV1) renewalInfo(originalTransactionID string) *PendingRenewalInfo {
+	for i, r := range n.UnifiedReceipt.PendingRenewalInfo {
+		if r.OriginalTransactionID == originalTransactionID {
+			return &n.UnifiedReceipt.PendingRenewalInfo[i]
+		}
+	}
+	return nil
+}
+
+func (t LatestReceiptInfo) toV2(bundleID string, environment Environment) JWSTransactionDecodedPayload {
+	quantity, _ := strconv.Atoi(t.Quantity)
+	p := JWSTransactionDecodedPayload{
+		AppAccountToken:             t.AppAccountToken,
+		BundleID:                    bundleID,
+		Environment:                 string(environment),
+		InAppOwnershipType:          t.InAppOwnershipType,
+		IsUpgraded:                  bool(t.IsUpgraded),
+		OriginalTransactionID:       t.OriginalTransactionID,
+		ProductID:                   t.ProductID,
+		Quantity:                    quantity,
+		SubscriptionGroupIdentifier: t.SubscriptionGroupIdentifier,
+		TransactionID:               t.TransactionID,
+		WebOrderLineItemID:          t.WebOrderLineItemID,
+
+		ExpiresDate:          toMillistamp(t.ExpiresDate),
+		OriginalPurchaseDate: toMillistamp(t.OriginalPurchaseDate),
+		PurchaseDate:         toMillistamp(t.PurchaseDate),
+		RevocationDate:       toMillistamp(t.CancellationDate),
+	}
+	if reason, err := strconv.ParseInt(t.CancellationReason, 10, 32); err == nil {
+		r := RevocationReason(reason)
+		p.RevocationReason = &r
+	}
+	return p
+}
+
+func (r PendingRenewalInfo) toV2(environment Environment) JWSRenewalInfoDecodedPayload {
+	p := JWSRenewalInfoDecodedPayload{
+		AutoRenewProductId:     r.AutoRenewProductID,
+		Environment:            string(environment),
+		IsInBillingRetryPeriod: bool(r.IsInBillingRetryPeriod),
+		OriginalTransactionId:  r.OriginalTransactionID,
+		ProductId:              r.ProductID,
+
+		GracePeriodExpiresDate: toMillistamp(r.GracePeriodExpiresDate),
+	}
+	if r.AutoRenewStatus {
+		p.AutoRenewStatus = AutoRenewStatusOn
+	}
+	if intent, err := strconv.ParseInt(r.ExpirationIntent, 10, 32); err == nil {
+		p.ExpirationIntent = ExpirationIntent(intent)
+	}
+	if status, err := strconv.ParseInt(r.PriceConsentStatus, 10, 32); err == nil {
+		p.PriceIncreaseStatus = PriceIncreaseStatus(status)
+	}
+	return p
+}
+
+func toMillistamp(m StringMillistamp) *Millistamp {
+	if m.IsZero() {
+		return nil
+	}
+	return &Millistamp{m.Time}
+}
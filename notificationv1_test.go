@@ -0,0 +1,165 @@
+package appstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testNotificationV1 is a V1 body as Apple sends it, with dates as strings of
+// milliseconds and flags as "true"/"false" or "1"/"0".
+const testNotificationV1 = `{
+	"auto_renew_product_id": "com.example.monthly",
+	"auto_renew_status": "false",
+	"auto_renew_status_change_date_ms": "1672531200000",
+	"bid": "com.example.app",
+	"bvrs": "42",
+	"environment": "PROD",
+	"notification_type": "DID_CHANGE_RENEWAL_STATUS",
+	"password": "shared-secret",
+	"unified_receipt": {
+		"environment": "Production",
+		"status": 0,
+		"latest_receipt_info": [
+			{
+				"expires_date_ms": "1669852800000",
+				"is_trial_period": "false",
+				"original_transaction_id": "1000000000000001",
+				"product_id": "com.example.monthly",
+				"purchase_date_ms": "1667260800000",
+				"quantity": "1",
+				"transaction_id": "1000000000000002"
+			},
+			{
+				"expires_date_ms": "1672531200000",
+				"is_trial_period": "1",
+				"original_transaction_id": "1000000000000001",
+				"product_id": "com.example.monthly",
+				"purchase_date_ms": "1669852800000",
+				"quantity": "1",
+				"transaction_id": "1000000000000003"
+			}
+		],
+		"pending_renewal_info": [
+			{
+				"auto_renew_product_id": "com.example.monthly",
+				"auto_renew_status": "0",
+				"is_in_billing_retry_period": "1",
+				"original_transaction_id": "1000000000000001",
+				"product_id": "com.example.monthly"
+			}
+		]
+	}
+}`
+
+func TestNotificationV1ToV2(t *testing.T) {
+	var n NotificationV1
+	if err := json.Unmarshal([]byte(testNotificationV1), &n); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.UnixMilli(1672531200000); !n.AutoRenewStatusChangeDate.Equal(want) {
+		t.Errorf("got status change date %v, want %v", n.AutoRenewStatusChangeDate, want)
+	}
+	if !n.UnifiedReceipt.LatestReceiptInfo[1].IsTrialPeriod {
+		t.Error(`is_trial_period "1" parsed as false`)
+	}
+
+	p, ok := n.ToV2()
+	if !ok {
+		t.Fatal("no V2 equivalent")
+	}
+	if p.NotificationType != NotificationTypeDidChangeRenewalStatus || p.Subtype != SubtypeAutoRenewDisabled {
+		t.Errorf("got %s/%s", p.NotificationType, p.Subtype)
+	}
+	if p.NotificationUUID != "" {
+		t.Errorf("got notification UUID %q, want none", p.NotificationUUID)
+	}
+	if p.Data.Environment != string(EnvironmentProduction) {
+		t.Errorf("got environment %s", p.Data.Environment)
+	}
+	tx := p.Data.TransactionInfo
+	if tx.TransactionID != "1000000000000003" {
+		t.Errorf("got transaction %s, want the latest", tx.TransactionID)
+	}
+	if tx.ExpiresDate == nil || !tx.ExpiresDate.Equal(time.UnixMilli(1672531200000)) {
+		t.Errorf("got expires date %v", tx.ExpiresDate)
+	}
+	if tx.Quantity != 1 || tx.BundleID != "com.example.app" {
+		t.Errorf("got quantity %d, bundle ID %s", tx.Quantity, tx.BundleID)
+	}
+	renewal := p.Data.RenewalInfo
+	if renewal.AutoRenewStatus != AutoRenewStatusOff || !renewal.IsInBillingRetryPeriod {
+		t.Errorf("got renewal info %+v", renewal)
+	}
+}
+
+func TestNotificationV1V2Type(t *testing.T) {
+	grace := PendingRenewalInfo{
+		OriginalTransactionID:  "1000000000000001",
+		GracePeriodExpiresDate: StringMillistamp{time.UnixMilli(1672531200000)},
+	}
+	receipt := func(renewal PendingRenewalInfo) UnifiedReceipt {
+		return UnifiedReceipt{
+			LatestReceiptInfo:  []LatestReceiptInfo{{OriginalTransactionID: "1000000000000001"}},
+			PendingRenewalInfo: []PendingRenewalInfo{renewal},
+		}
+	}
+	tests := []struct {
+		name        string
+		n           NotificationV1
+		wantType    NotificationType
+		wantSubtype Subtype
+	}{
+		{"renewal enabled", NotificationV1{NotificationType: "DID_CHANGE_RENEWAL_STATUS", AutoRenewStatus: true},
+			NotificationTypeDidChangeRenewalStatus, SubtypeAutoRenewEnabled},
+		{"renewal disabled", NotificationV1{NotificationType: "DID_CHANGE_RENEWAL_STATUS"},
+			NotificationTypeDidChangeRenewalStatus, SubtypeAutoRenewDisabled},
+		{"grace period", NotificationV1{NotificationType: "DID_FAIL_TO_RENEW", UnifiedReceipt: receipt(grace)},
+			NotificationTypeDidFailToRenew, SubtypeGracePeriod},
+		{"no grace period", NotificationV1{NotificationType: "DID_FAIL_TO_RENEW",
+			UnifiedReceipt: receipt(PendingRenewalInfo{OriginalTransactionID: "1000000000000001"})},
+			NotificationTypeDidFailToRenew, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotSubtype, ok := tt.n.V2Type()
+			if !ok || gotType != tt.wantType || gotSubtype != tt.wantSubtype {
+				t.Errorf("got %s/%s, %v, want %s/%s", gotType, gotSubtype, ok, tt.wantType, tt.wantSubtype)
+			}
+		})
+	}
+	if _, _, ok := (&NotificationV1{NotificationType: "UNKNOWN"}).V2Type(); ok {
+		t.Error("unknown type: got a V2 equivalent")
+	}
+}
+
+func TestNotificationV1Handler(t *testing.T) {
+	called := false
+	fn := func(context.Context, *NotificationV1) error {
+		called = true
+		return nil
+	}
+	tests := []struct {
+		name   string
+		secret string
+		want   int
+	}{
+		{"shared secret", "shared-secret", http.StatusOK},
+		{"wrong password", "other-secret", http.StatusUnauthorized},
+		{"empty shared secret", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = false
+			h := NewNotificationV1Handler(tt.secret, fn)
+			if w := serveNotification(h, http.MethodPost, testNotificationV1); w.Code != tt.want {
+				t.Fatalf("got status %d, want %d", w.Code, tt.want)
+			}
+			if called != (tt.want == http.StatusOK) {
+				t.Errorf("callback ran: %v", called)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	contentTypeOCSPRequest = "application/ocsp-request"
+	// ocspClockSkew is how far ahead of the local clock a responder's
+	// thisUpdate may be.
+	ocspClockSkew = 5 * time.Minute
+)
+
+var ErrCertificateRevoked = errors.New("certificate has been revoked")
+
+// OCSPChecker checks the revocation status of JWS signing certificates with
+// the OCSP responder named in each certificate. Good responses are cached
+// until their nextUpdate time.
+type OCSPChecker struct {
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]time.Time
+}
+
+// NewOCSPChecker creates an OCSPChecker that sends requests over transport,
+// or http.DefaultTransport when transport is nil.
+func NewOCSPChecker(transport http.RoundTripper) *OCSPChecker {
+	return &OCSPChecker{
+		httpClient: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+		now:        time.Now,
+		cache:      make(map[string]time.Time),
+	}
+}
+
+// Check returns an error unless the responder for cert reports it as good.
+func (o *OCSPChecker) Check(cert, issuer *x509.Certificate) error {
+	key := string(issuer.RawSubjectPublicKeyInfo) + cert.SerialNumber.String()
+	now := o.now()
+	o.mu.Lock()
+	nextUpdate, ok := o.cache[key]
+	o.mu.Unlock()
+	if ok && now.Before(nextUpdate) {
+		return nil
+	}
+
+	resp, err := o.fetch(cert, issuer)
+	if err != nil {
+		return err
+	}
+	// ParseResponseForCert does not check the validity period, so a stale
+	// good response replayed for a revoked certificate would pass.
+	if resp.ThisUpdate.After(now.Add(ocspClockSkew)) {
+		return fmt.Errorf("%s: OCSP response thisUpdate %s is in the future",
+			cert.Subject.CommonName, resp.ThisUpdate.Format(time.RFC3339))
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(now) {
+		return fmt.Errorf("%s: OCSP response expired at %s",
+			cert.Subject.CommonName, resp.NextUpdate.Format(time.RFC3339))
+	}
+	switch resp.Status {
+	case ocsp.Good:
+	case ocsp.Revoked:
+		return fmt.Errorf("%s: %w", cert.Subject.CommonName, ErrCertificateRevoked)
+	default:
+		return fmt.Errorf("%s: OCSP status unknown", cert.Subject.CommonName)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if resp.NextUpdate.IsZero() {
+		delete(o.cache, key)
+	} else {
+		o.cache[key] = resp.NextUpdate
+	}
+	return nil
+}
+
+func (o *OCSPChecker) fetch(cert, issuer *x509.Certificate) (*ocsp.Response, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, fmt.Errorf("%s: no OCSP server in certificate", cert.Subject.CommonName)
+	}
+	reqData, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not create OCSP request: %v", err)
+	}
+	resp, err := o.httpClient.Post(cert.OCSPServer[0], contentTypeOCSPRequest, bytes.NewReader(reqData))
+	if err != nil {
+		return nil, fmt.Errorf("could not send OCSP request: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read OCSP response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder status: %s", resp.Status)
+	}
+	parsed, err := ocsp.ParseResponseForCert(data, cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OCSP response: %v", err)
+	}
+	return parsed, nil
+}
@@ -0,0 +1,192 @@
+package appstore
+
+import (
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// testResponder is an OCSP responder for the certificates of a testChain.
+type testResponder struct {
+	t      *testing.T
+	server *httptest.Server
+	chain  testChain
+
+	mu         sync.Mutex
+	status     map[string]int
+	thisUpdate time.Time
+	nextUpdate time.Time
+	requests   int
+}
+
+func newTestResponder(t *testing.T) *testResponder {
+	r := &testResponder{
+		t:          t,
+		status:     make(map[string]int),
+		thisUpdate: time.Now().Add(-time.Minute),
+		nextUpdate: time.Now().Add(time.Hour),
+	}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	t.Cleanup(r.server.Close)
+	r.chain = newTestChain(t, r.server.URL)
+	return r
+}
+
+func (r *testResponder) setStatus(cert *x509.Certificate, status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status[cert.SerialNumber.String()] = status
+}
+
+func (r *testResponder) setValidity(thisUpdate, nextUpdate time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.thisUpdate, r.nextUpdate = thisUpdate, nextUpdate
+}
+
+func (r *testResponder) requestCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requests
+}
+
+func (r *testResponder) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parsed, err := ocsp.ParseRequest(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.mu.Lock()
+	r.requests++
+	status := r.status[parsed.SerialNumber.String()]
+	thisUpdate, nextUpdate := r.thisUpdate, r.nextUpdate
+	r.mu.Unlock()
+
+	// The root issues the intermediate, which issues the leaf.
+	issuer := r.chain.interm
+	if parsed.SerialNumber.Cmp(r.chain.interm.cert.SerialNumber) == 0 {
+		issuer = r.chain.root
+	}
+	template := ocsp.Response{
+		Status:       status,
+		SerialNumber: parsed.SerialNumber,
+		ThisUpdate:   thisUpdate,
+		NextUpdate:   nextUpdate,
+	}
+	if status == ocsp.Revoked {
+		template.RevokedAt = time.Now().Add(-time.Minute)
+	}
+	resp, err := ocsp.CreateResponse(issuer.cert, issuer.cert, template, issuer.key)
+	if err != nil {
+		r.t.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+func TestOCSPCheckerStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		thisUpdate time.Duration
+		nextUpdate time.Duration
+		wantErr    bool
+	}{
+		{"good", ocsp.Good, -time.Minute, time.Hour, false},
+		{"revoked", ocsp.Revoked, -time.Minute, time.Hour, true},
+		{"unknown", ocsp.Unknown, -time.Minute, time.Hour, true},
+		{"stale", ocsp.Good, -48 * time.Hour, -24 * time.Hour, true},
+		{"not yet valid", ocsp.Good, time.Hour, 2 * time.Hour, true},
+		{"slightly ahead", ocsp.Good, time.Minute, time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestResponder(t)
+			r.setStatus(r.chain.leaf.cert, tt.status)
+			r.setValidity(time.Now().Add(tt.thisUpdate), time.Now().Add(tt.nextUpdate))
+			checker := NewOCSPChecker(r.server.Client().Transport)
+
+			err := checker.Check(r.chain.leaf.cert, r.chain.interm.cert)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if revoked := errors.Is(err, ErrCertificateRevoked); revoked != (tt.status == ocsp.Revoked) {
+				t.Errorf("got %v, ErrCertificateRevoked %v", err, revoked)
+			}
+		})
+	}
+}
+
+func TestOCSPCheckerCachesUntilNextUpdate(t *testing.T) {
+	r := newTestResponder(t)
+	checker := NewOCSPChecker(r.server.Client().Transport)
+	now := time.Now()
+	checker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := checker.Check(r.chain.leaf.cert, r.chain.interm.cert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := r.requestCount(); n != 1 {
+		t.Fatalf("got %d requests before nextUpdate, want 1", n)
+	}
+
+	now = r.nextUpdate.Add(time.Second)
+	r.setValidity(now, now.Add(time.Hour))
+	r.setStatus(r.chain.leaf.cert, ocsp.Revoked)
+	if err := checker.Check(r.chain.leaf.cert, r.chain.interm.cert); !errors.Is(err, ErrCertificateRevoked) {
+		t.Fatalf("after nextUpdate: got %v, want ErrCertificateRevoked", err)
+	}
+	if n := r.requestCount(); n != 2 {
+		t.Fatalf("got %d requests after nextUpdate, want 2", n)
+	}
+}
+
+func TestVerifyTransactionOCSP(t *testing.T) {
+	r := newTestResponder(t)
+	signed := signTestJWS(t, r.chain.leaf.key, r.chain.x5c(), testTransaction())
+
+	v := newTestVerifier(t, r.chain.root.cert, WithOCSP(NewOCSPChecker(r.server.Client().Transport)))
+	if _, err := v.VerifyTransaction(signed); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.requestCount(); n != 2 {
+		t.Fatalf("got %d requests, want 2 for the leaf and intermediate", n)
+	}
+
+	r.setStatus(r.chain.interm.cert, ocsp.Revoked)
+	v = newTestVerifier(t, r.chain.root.cert, WithOCSP(NewOCSPChecker(r.server.Client().Transport)))
+	if _, err := v.VerifyTransaction(signed); !errors.Is(err, ErrCertificateRevoked) {
+		t.Fatalf("revoked intermediate: got %v, want ErrCertificateRevoked", err)
+	}
+}
+
+func TestVerifyTransactionOfflineSkipsOCSP(t *testing.T) {
+	r := newTestResponder(t)
+	r.setStatus(r.chain.leaf.cert, ocsp.Revoked)
+	signed := signTestJWS(t, r.chain.leaf.key, r.chain.x5c(), testTransaction())
+
+	checker := NewOCSPChecker(r.server.Client().Transport)
+	v := newTestVerifier(t, r.chain.root.cert, WithOCSP(checker), WithOfflineVerification())
+	if _, err := v.VerifyTransaction(signed); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.requestCount(); n != 0 {
+		t.Fatalf("got %d OCSP requests in offline mode, want 0", n)
+	}
+}
@@ -28,7 +28,7 @@ func (r *OrderLookupResponse) DecodeJWS(keyFunc jwt.Keyfunc, data []byte) error
 		r.Transactions = append(r.Transactions, transaction)
 	}
 	if len(errs) > 0 {
-		return fmt.Errorf("could not decode all transactions: %v", errors.Join(errs...))
+		return fmt.Errorf("could not decode all transactions: %w", errors.Join(errs...))
 	}
 	return nil
 }
@@ -20,6 +20,14 @@ func (m *Millistamp) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// time returns the zero time for a missing timestamp.
+func (m *Millistamp) time() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return m.Time
+}
+
 type JWSData string
 
 func (j JWSData) Decode(keyFunc jwt.Keyfunc, claims jwt.Claims) error {
@@ -29,45 +37,132 @@ func (j JWSData) Decode(keyFunc jwt.Keyfunc, claims jwt.Claims) error {
 	return nil
 }
 
+// JWSRenewalInfoDecodedPayload is signed subscription renewal info.
+// RenewalPrice is in milliunits of Currency.
 type JWSRenewalInfoDecodedPayload struct {
-	AutoRenewProductId     string `json:"autoRenewProductId"`
-	AutoRenewStatus        int32  `json:"autoRenewStatus"`
-	Environment            string `json:"environment"`
-	ExpirationIntent       int32  `json:"expirationIntent"`
-	IsInBillingRetryPeriod bool   `json:"isInBillingRetryPeriod"`
-	OfferIdentifier        string `json:"offerIdentifier"`
-	OfferType              int32  `json:"offerType"`
-	OriginalTransactionId  string `json:"originalTransactionId"`
-	PriceIncreaseStatus    int32  `json:"priceIncreaseStatus"`
-	ProductId              string `json:"productId"`
+	AppAccountToken         string              `json:"appAccountToken"`
+	AppTransactionId        string              `json:"appTransactionId"`
+	AutoRenewProductId      string              `json:"autoRenewProductId"`
+	AutoRenewStatus         AutoRenewStatus     `json:"autoRenewStatus"`
+	Currency                string              `json:"currency"`
+	EligibleWinBackOfferIds []string            `json:"eligibleWinBackOfferIds"`
+	Environment             string              `json:"environment"`
+	ExpirationIntent        ExpirationIntent    `json:"expirationIntent"`
+	IsInBillingRetryPeriod  bool                `json:"isInBillingRetryPeriod"`
+	OfferDiscountType       OfferDiscountType   `json:"offerDiscountType"`
+	OfferIdentifier         string              `json:"offerIdentifier"`
+	OfferPeriod             string              `json:"offerPeriod"`
+	OfferType               OfferType           `json:"offerType"`
+	OriginalTransactionId   string              `json:"originalTransactionId"`
+	PriceIncreaseStatus     PriceIncreaseStatus `json:"priceIncreaseStatus"`
+	ProductId               string              `json:"productId"`
+	RenewalPrice            int64               `json:"renewalPrice"`
 
 	GracePeriodExpiresDate      *Millistamp `json:"gracePeriodExpiresDate"`
 	RecentSubscriptionStartDate *Millistamp `json:"recentSubscriptionStartDate"`
+	RenewalDate                 *Millistamp `json:"renewalDate"`
 	SignedDate                  *Millistamp `json:"signedDate"`
 }
 
+// WillAutoRenew reports whether the subscription renews at the end of the
+// current period.
+func (p JWSRenewalInfoDecodedPayload) WillAutoRenew() bool {
+	return p.AutoRenewStatus == AutoRenewStatusOn
+}
+
+// InGracePeriod reports whether Apple is retrying billing at now while the
+// customer keeps access to the subscription.
+func (p JWSRenewalInfoDecodedPayload) InGracePeriod(now time.Time) bool {
+	return p.IsInBillingRetryPeriod && p.GracePeriodExpiresDate != nil && now.Before(p.GracePeriodExpiresDate.Time)
+}
+
 func (p JWSRenewalInfoDecodedPayload) Valid() error {
 	return nil
 }
 
+func (p JWSRenewalInfoDecodedPayload) appClaims() (string, int64, string) {
+	return "", 0, p.Environment
+}
+
+func (p JWSRenewalInfoDecodedPayload) signedDate() time.Time {
+	return p.SignedDate.time()
+}
+
+// JWSTransactionDecodedPayload is a signed transaction. Price is in
+// milliunits of Currency, e.g. 1990 for 1.99 USD.
 type JWSTransactionDecodedPayload struct {
-	TransactionID               string `json:"transactionId,omitempty"`
-	OriginalTransactionID       string `json:"originalTransactionId,omitempty"`
-	WebOrderLineItemID          string `json:"webOrderLineItemId,omitempty"`
-	BundleID                    string `json:"bundleId,omitempty"`
-	ProductID                   string `json:"productId,omitempty"`
-	SubscriptionGroupIdentifier string `json:"subscriptionGroupIdentifier,omitempty"`
-	Quantity                    int    `json:"quantity,omitempty"`
-	Type                        string `json:"type,omitempty"`
-	InAppOwnershipType          string `json:"inAppOwnershipType,omitempty"`
-	Environment                 string `json:"environment,omitempty"`
+	TransactionID               string             `json:"transactionId,omitempty"`
+	OriginalTransactionID       string             `json:"originalTransactionId,omitempty"`
+	WebOrderLineItemID          string             `json:"webOrderLineItemId,omitempty"`
+	BundleID                    string             `json:"bundleId,omitempty"`
+	ProductID                   string             `json:"productId,omitempty"`
+	SubscriptionGroupIdentifier string             `json:"subscriptionGroupIdentifier,omitempty"`
+	Quantity                    int                `json:"quantity,omitempty"`
+	Type                        ProductType        `json:"type,omitempty"`
+	InAppOwnershipType          InAppOwnershipType `json:"inAppOwnershipType,omitempty"`
+	Environment                 string             `json:"environment,omitempty"`
+	DeviceVerification          string             `json:"deviceVerification,omitempty"`
+	DeviceVerificationNonce     string             `json:"deviceVerificationNonce,omitempty"`
+	AppAccountToken             string             `json:"appAccountToken,omitempty"`
+	AppTransactionID            string             `json:"appTransactionId,omitempty"`
+	Currency                    string             `json:"currency,omitempty"`
+	IsUpgraded                  bool               `json:"isUpgraded,omitempty"`
+	OfferDiscountType           OfferDiscountType  `json:"offerDiscountType,omitempty"`
+	OfferIdentifier             string             `json:"offerIdentifier,omitempty"`
+	OfferPeriod                 string             `json:"offerPeriod,omitempty"`
+	OfferType                   OfferType          `json:"offerType,omitempty"`
+	OriginalPlatform            OriginalPlatform   `json:"originalPlatform,omitempty"`
+	Price                       int64              `json:"price,omitempty"`
+	RevocationReason            *RevocationReason  `json:"revocationReason,omitempty"`
+	Storefront                  string             `json:"storefront,omitempty"`
+	StorefrontID                string             `json:"storefrontId,omitempty"`
+	TransactionReason           TransactionReason  `json:"transactionReason,omitempty"`
 
 	PurchaseDate         *Millistamp `json:"purchaseDate"`
 	OriginalPurchaseDate *Millistamp `json:"originalPurchaseDate"`
 	ExpiresDate          *Millistamp `json:"expiresDate"`
+	RevocationDate       *Millistamp `json:"revocationDate"`
 	SignedDate           *Millistamp `json:"signedDate"`
 }
 
 func (p JWSTransactionDecodedPayload) Valid() error {
 	return nil
 }
+
+func (p JWSTransactionDecodedPayload) appClaims() (string, int64, string) {
+	return p.BundleID, 0, p.Environment
+}
+
+func (p JWSTransactionDecodedPayload) signedDate() time.Time {
+	return p.SignedDate.time()
+}
+
+type JWSAppTransactionDecodedPayload struct {
+	AppAppleID                 int64            `json:"appAppleId,omitempty"`
+	AppTransactionID           string           `json:"appTransactionId,omitempty"`
+	ApplicationVersion         string           `json:"applicationVersion,omitempty"`
+	BundleID                   string           `json:"bundleId,omitempty"`
+	DeviceVerification         string           `json:"deviceVerification,omitempty"`
+	DeviceVerificationNonce    string           `json:"deviceVerificationNonce,omitempty"`
+	OriginalApplicationVersion string           `json:"originalApplicationVersion,omitempty"`
+	OriginalPlatform           OriginalPlatform `json:"originalPlatform,omitempty"`
+	ReceiptType                string           `json:"receiptType,omitempty"`
+	VersionExternalIdentifier  int64            `json:"versionExternalIdentifier,omitempty"`
+
+	OriginalPurchaseDate *Millistamp `json:"originalPurchaseDate"`
+	PreorderDate         *Millistamp `json:"preorderDate"`
+	ReceiptCreationDate  *Millistamp `json:"receiptCreationDate"`
+	SignedDate           *Millistamp `json:"signedDate"`
+}
+
+func (p JWSAppTransactionDecodedPayload) Valid() error {
+	return nil
+}
+
+func (p JWSAppTransactionDecodedPayload) appClaims() (string, int64, string) {
+	return p.BundleID, p.AppAppleID, p.ReceiptType
+}
+
+func (p JWSAppTransactionDecodedPayload) signedDate() time.Time {
+	return p.SignedDate.time()
+}
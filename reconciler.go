@@ -0,0 +1,165 @@
+package appstore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erictse/appstore-go/notification"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	defaultReconcileWindow = 7 * 24 * time.Hour
+	maxReconcileWindow     = 180 * 24 * time.Hour
+)
+
+// Reconciler recovers notifications missed while the notification endpoint
+// was unavailable. It walks the notification history and replays every
+// notification its SeenStore has no claim for.
+type Reconciler struct {
+	client *Client
+	store  SeenStore
+	fn     NotificationFunc
+	window time.Duration
+}
+
+type ReconcilerOption func(*Reconciler)
+
+// WithReconcileWindow sets the length of the windows the notification history
+// is requested in. The default is 7 days and the maximum 180 days.
+func WithReconcileWindow(window time.Duration) ReconcilerOption {
+	return func(r *Reconciler) {
+		r.window = window
+	}
+}
+
+// NewReconciler creates a Reconciler that replays missed notifications
+// through fn, the NotificationFunc used for live notifications. Pass the
+// SeenStore fn is deduplicated with, if any; with a nil store every
+// notification is replayed and fn must tolerate duplicates.
+func NewReconciler(c *Client, store SeenStore, fn NotificationFunc, opts ...ReconcilerOption) *Reconciler {
+	r := &Reconciler{
+		client: c,
+		store:  store,
+		fn:     fn,
+		window: defaultReconcileWindow,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if r.window <= 0 || r.window > maxReconcileWindow {
+		r.window = maxReconcileWindow
+	}
+	return r
+}
+
+// ReconcileFailure is a notification that could not be replayed. For one
+// that failed verification, NotificationUUID is read from the unverified
+// payload.
+type ReconcileFailure struct {
+	NotificationUUID string
+	Err              error
+}
+
+// ReconcileReport tells what a Reconcile call found. Recovered lists the
+// notificationUUIDs replayed successfully and Skipped counts those processed
+// before.
+type ReconcileReport struct {
+	Windows   int
+	Scanned   int
+	Skipped   int
+	Recovered []string
+	Failed    []ReconcileFailure
+}
+
+// Reconcile replays the notifications sent between start and end that were
+// not processed yet. Apple keeps 180 days of notification history. The
+// SeenStore must remember notifications since start, i.e. its TTL must be
+// longer than end minus start, or notifications it forgot are replayed.
+// Failed replays are reported, not returned as an error, so one bad
+// notification does not stop the rest.
+func (r *Reconciler) Reconcile(ctx context.Context, start, end time.Time, opts ...notification.HistoryOption) (ReconcileReport, error) {
+	var report ReconcileReport
+	for windowStart := start; windowStart.Before(end); windowStart = windowStart.Add(r.window) {
+		windowEnd := windowStart.Add(r.window)
+		if windowEnd.After(end) {
+			windowEnd = end
+		}
+		report.Windows++
+		if err := r.reconcileWindow(ctx, windowStart, windowEnd, opts, &report); err != nil {
+			return report, fmt.Errorf("appleapi: reconcile %s to %s: %w",
+				windowStart.Format(time.RFC3339), windowEnd.Format(time.RFC3339), err)
+		}
+	}
+	return report, nil
+}
+
+func (r *Reconciler) reconcileWindow(ctx context.Context, start, end time.Time, opts []notification.HistoryOption,
+	report *ReconcileReport) error {
+
+	pageOpts := opts
+	for {
+		resp, err := r.client.GetNotificationHistory(ctx, start, end, pageOpts...)
+		if err != nil && !partiallyDecoded(resp) {
+			return err
+		}
+		for _, item := range resp.NotificationHistory {
+			if item.Err != nil {
+				report.Scanned++
+				report.Failed = append(report.Failed, ReconcileFailure{
+					NotificationUUID: unverifiedNotificationUUID(item.SignedPayload),
+					Err:              item.Err,
+				})
+				continue
+			}
+			r.replay(ctx, &item.Payload, report)
+		}
+		if !resp.HasMore {
+			return nil
+		}
+		pageOpts = append(opts[:len(opts):len(opts)], notification.WithNextToken(resp.PaginationToken))
+	}
+}
+
+func (r *Reconciler) replay(ctx context.Context, p *ResponseBodyV2DecodedPayload, report *ReconcileReport) {
+	report.Scanned++
+	ran := false
+	process := func(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+		ran = true
+		return r.fn(ctx, p)
+	}
+	if r.store != nil {
+		process = Deduplicate(r.store)(process)
+	}
+	err := process(ctx, p)
+	switch {
+	case err != nil:
+		report.Failed = append(report.Failed, ReconcileFailure{NotificationUUID: p.NotificationUUID, Err: err})
+	case ran:
+		report.Recovered = append(report.Recovered, p.NotificationUUID)
+	default:
+		report.Skipped++
+	}
+}
+
+// partiallyDecoded reports whether resp was received and only some of its
+// notifications failed to decode.
+func partiallyDecoded(resp NotificationHistoryResponse) bool {
+	for _, item := range resp.NotificationHistory {
+		if item.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// unverifiedNotificationUUID reads the notificationUUID of a notification
+// that failed verification, for reporting only.
+func unverifiedNotificationUUID(signedPayload JWSData) string {
+	var p ResponseBodyV2DecodedPayload
+	if _, _, err := jwt.NewParser().ParseUnverified(string(signedPayload), &p); err != nil {
+		return ""
+	}
+	return p.NotificationUUID
+}
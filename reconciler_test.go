@@ -0,0 +1,85 @@
+package appstore
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReconcilerReportsUndecodableNotifications(t *testing.T) {
+	chain := newTestChain(t, "")
+	forger := newTestChain(t, "")
+	signNotification := func(signer testChain, uuid string) JWSData {
+		claims := testNotification()
+		claims["notificationUUID"] = uuid
+		return JWSData(signTestJWS(t, signer.leaf.key, signer.x5c(), claims))
+	}
+	item := func(signedPayload JWSData) map[string]any {
+		return map[string]any{"signedPayload": signedPayload, "firstSendAttemptResult": "SUCCESS"}
+	}
+	pages := map[string]map[string]any{
+		"": {
+			"notificationHistory": []map[string]any{
+				item(signNotification(chain, "c7e3a3f6-1b41-4d8f-9e0a-0f5b2c5d7a01")),
+				item(signNotification(forger, "c7e3a3f6-1b41-4d8f-9e0a-0f5b2c5d7a02")),
+			},
+			"hasMore":         true,
+			"paginationToken": "page-2",
+		},
+		"page-2": {
+			"notificationHistory": []map[string]any{
+				item(signNotification(chain, "c7e3a3f6-1b41-4d8f-9e0a-0f5b2c5d7a03")),
+			},
+			"hasMore": false,
+		},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.Query().Get("paginationToken")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer server.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	optSigner, err := WithClaimsAndSigner(testBundleID, "issuer", "ABC0DE1F23", "1234AB5678", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	optCerts, err := WithAppleRootCerts(chain.root.cert.Raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(optCerts, optSigner, WithSandbox())
+	if err != nil {
+		t.Fatal(err)
+	}
+	*c.host = server.URL
+
+	var processed []string
+	r := NewReconciler(c, NewMemorySeenStore(24*time.Hour), func(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+		processed = append(processed, p.NotificationUUID)
+		return nil
+	})
+	report, err := r.Reconcile(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Scanned != 3 || len(report.Recovered) != 2 || len(processed) != 2 {
+		t.Errorf("got report %+v, processed %v", report, processed)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].NotificationUUID != "c7e3a3f6-1b41-4d8f-9e0a-0f5b2c5d7a02" {
+		t.Errorf("got failures %+v", report.Failed)
+	}
+}
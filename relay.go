@@ -0,0 +1,227 @@
+package appstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	headerNotificationType      = "X-Appstore-Notification-Type"
+	headerNotificationSubtype   = "X-Appstore-Notification-Subtype"
+	headerNotificationUUID      = "X-Appstore-Notification-Uuid"
+	headerEnvironment           = "X-Appstore-Environment"
+	headerTransactionID         = "X-Appstore-Transaction-Id"
+	headerOriginalTransactionID = "X-Appstore-Original-Transaction-Id"
+
+	defaultRelayMaxAttempts = 3
+	defaultRelayBackoff     = 500 * time.Millisecond
+	// optionalRelayTimeout bounds delivery to optional targets, which is
+	// detached from Apple's request.
+	optionalRelayTimeout = 2 * time.Minute
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// RelayTarget is a downstream endpoint a NotificationRelay forwards
+// notifications to.
+type RelayTarget struct {
+	Name string
+	URL  string
+	// Required targets must accept a notification before Apple is told it
+	// was received. Apple retries otherwise, so targets should deduplicate
+	// on notificationUUID.
+	// Other targets are delivered to in the background, without delaying
+	// the response to Apple.
+	Required bool
+	// Environments limits the target to notifications from these
+	// environments. All environments are forwarded when empty.
+	Environments []Environment
+	// MaxAttempts defaults to 3.
+	MaxAttempts int
+}
+
+func (t RelayTarget) accepts(environment Environment) bool {
+	if len(t.Environments) == 0 {
+		return true
+	}
+	for _, e := range t.Environments {
+		if e == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationRelay is an http.Handler that verifies App Store Server
+// Notifications once and forwards the original body to several downstream
+// targets, for apps that need notifications in more services than the one
+// URL per environment Apple allows.
+type NotificationRelay struct {
+	handler       *NotificationHandler
+	targets       []RelayTarget
+	httpClient    *http.Client
+	backoff       time.Duration
+	deadLetterDir string
+	decodedHeader bool
+}
+
+type RelayOption func(*NotificationRelay)
+
+func WithRelayHTTPClient(httpClient *http.Client) RelayOption {
+	return func(r *NotificationRelay) {
+		r.httpClient = httpClient
+	}
+}
+
+// WithDeadLetterDir writes the body of every notification a target did not
+// accept to dir, named after the notification and target, for replay.
+func WithDeadLetterDir(dir string) RelayOption {
+	return func(r *NotificationRelay) {
+		r.deadLetterDir = dir
+	}
+}
+
+// WithDecodedHeaders adds headers with the notification type, subtype, UUID,
+// environment and, when present, the transaction and original transaction
+// IDs, so targets can route without verifying the signed payload themselves.
+func WithDecodedHeaders() RelayOption {
+	return func(r *NotificationRelay) {
+		r.decodedHeader = true
+	}
+}
+
+func NewNotificationRelay(c *Client, targets []RelayTarget, opts ...RelayOption) *NotificationRelay {
+	r := &NotificationRelay{
+		targets:    targets,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		backoff:    defaultRelayBackoff,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.handler = NewNotificationHandler(c, r.forward)
+	return r
+}
+
+func (r *NotificationRelay) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.handler.ServeHTTP(w, req)
+}
+
+func (r *NotificationRelay) forward(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+	body, ok := RawNotificationBody(ctx)
+	if !ok {
+		return errors.New("appleapi: relay: no notification body")
+	}
+	header := make(http.Header)
+	header.Set(headerContentType, contentTypeJSON)
+	_, _, environment := p.appClaims()
+	if r.decodedHeader {
+		header.Set(headerNotificationType, string(p.NotificationType))
+		header.Set(headerNotificationSubtype, string(p.Subtype))
+		header.Set(headerNotificationUUID, p.NotificationUUID)
+		header.Set(headerEnvironment, environment)
+		if p.Data != nil && p.Data.TransactionInfo.TransactionID != "" {
+			header.Set(headerTransactionID, p.Data.TransactionInfo.TransactionID)
+			header.Set(headerOriginalTransactionID, p.Data.TransactionInfo.OriginalTransactionID)
+		}
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, t := range r.targets {
+		if !t.accepts(Environment(environment)) {
+			continue
+		}
+		if !t.Required {
+			go func(t RelayTarget) {
+				ctx, cancel := context.WithTimeout(context.Background(), optionalRelayTimeout)
+				defer cancel()
+				r.deliverOrDeadLetter(ctx, p, t, header, body)
+			}(t)
+			continue
+		}
+		wg.Add(1)
+		go func(t RelayTarget) {
+			defer wg.Done()
+			if err := r.deliverOrDeadLetter(ctx, p, t, header, body); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", t.Name, err))
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return fmt.Errorf("appleapi: relay: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (r *NotificationRelay) deliverOrDeadLetter(ctx context.Context, p *ResponseBodyV2DecodedPayload, t RelayTarget,
+	header http.Header, body []byte) error {
+
+	err := r.deliver(ctx, t, header, body)
+	if err == nil {
+		return nil
+	}
+	if dlErr := r.deadLetter(p, t, body); dlErr != nil {
+		err = fmt.Errorf("%w; dead letter: %v", err, dlErr)
+	}
+	return err
+}
+
+func (r *NotificationRelay) deliver(ctx context.Context, t RelayTarget, header http.Header, body []byte) error {
+	maxAttempts := t.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRelayMaxAttempts
+	}
+	backoff := r.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = r.post(ctx, t.URL, header, body); err == nil || attempt >= maxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%v: %w", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (r *NotificationRelay) post(ctx context.Context, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header.Clone()
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (r *NotificationRelay) deadLetter(p *ResponseBodyV2DecodedPayload, t RelayTarget, body []byte) error {
+	if r.deadLetterDir == "" {
+		return nil
+	}
+	name := fmt.Sprintf("%s-%s-%d.json", p.NotificationUUID, t.Name, time.Now().UnixNano())
+	return os.WriteFile(filepath.Join(r.deadLetterDir, unsafeFileChars.ReplaceAllString(name, "_")), body, 0o600)
+}
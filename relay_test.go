@@ -0,0 +1,114 @@
+package appstore
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestTarget starts a relay target that passes each request it receives to
+// handle.
+func newTestTarget(t *testing.T, handle func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handle))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNotificationRelay(t *testing.T) {
+	chain := newTestChain(t, "")
+	c := newTestClient(t, chain)
+	body := notificationBody(signTestJWS(t, chain.leaf.key, chain.x5c(), testNotification()))
+
+	received := make(chan *http.Request, 2)
+	bodies := make(chan string, 2)
+	accept := func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- string(data)
+	}
+	required := newTestTarget(t, accept)
+	optional := newTestTarget(t, accept)
+
+	relay := NewNotificationRelay(c, []RelayTarget{
+		{Name: "required", URL: required.URL, Required: true},
+		{Name: "optional", URL: optional.URL},
+		{Name: "production", URL: optional.URL, Environments: []Environment{EnvironmentProduction}},
+	}, WithDecodedHeaders())
+	if w := serveNotification(relay, http.MethodPost, body); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case r := <-received:
+			if got := r.Header.Get(headerNotificationType); got != string(NotificationTypeTest) {
+				t.Errorf("got %s header %q", headerNotificationType, got)
+			}
+			if got := r.Header.Get(headerEnvironment); got != string(EnvironmentSandbox) {
+				t.Errorf("got %s header %q", headerEnvironment, got)
+			}
+			if got := <-bodies; got != body {
+				t.Errorf("got body %s, want the body Apple sent", got)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of 2 targets received the notification", i)
+		}
+	}
+	select {
+	case <-received:
+		t.Error("target for another environment received the notification")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotificationRelayHangingOptionalTarget(t *testing.T) {
+	chain := newTestChain(t, "")
+	c := newTestClient(t, chain)
+	body := notificationBody(signTestJWS(t, chain.leaf.key, chain.x5c(), testNotification()))
+
+	required := newTestTarget(t, func(http.ResponseWriter, *http.Request) {})
+	hang := make(chan struct{})
+	hanging := newTestTarget(t, func(http.ResponseWriter, *http.Request) {
+		<-hang
+	})
+	t.Cleanup(func() { close(hang) })
+
+	relay := NewNotificationRelay(c, []RelayTarget{
+		{Name: "required", URL: required.URL, Required: true},
+		{Name: "hanging", URL: hanging.URL},
+	})
+	start := time.Now()
+	if w := serveNotification(relay, http.MethodPost, body); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("response took %v waiting for an optional target", elapsed)
+	}
+}
+
+func TestNotificationRelayRequiredTargetFails(t *testing.T) {
+	chain := newTestChain(t, "")
+	c := newTestClient(t, chain)
+	body := notificationBody(signTestJWS(t, chain.leaf.key, chain.x5c(), testNotification()))
+
+	failing := newTestTarget(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	dir := t.TempDir()
+	relay := NewNotificationRelay(c, []RelayTarget{
+		{Name: "required", URL: failing.URL, Required: true, MaxAttempts: 1},
+	}, WithDeadLetterDir(dir))
+	if w := serveNotification(relay, http.MethodPost, body); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got status %d, want 500", w.Code)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(entries))
+	}
+}
@@ -0,0 +1,287 @@
+package appstore
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SeenStore records which notifications are processed, keyed on
+// notificationUUID, so that notifications Apple sends more than once are only
+// processed once. Implementations must be safe for concurrent use.
+type SeenStore interface {
+	// Claim marks id as being processed and returns a token identifying the
+	// claim. ok is false when the notification was processed already. It
+	// returns ErrClaimInProgress while another claim on id is held.
+	Claim(ctx context.Context, id string) (token string, ok bool, err error)
+	// Complete marks id as processed, even when its claim was taken over
+	// after the lease ran out, since the notification was processed.
+	Complete(ctx context.Context, id string) error
+	// Release removes the claim on id made with token so a retry can process
+	// the notification. It leaves claims taken over since, and completed
+	// ones, in place.
+	Release(ctx context.Context, id, token string) error
+}
+
+// ErrClaimInProgress is returned by SeenStore.Claim for a notification that
+// is still being processed.
+var ErrClaimInProgress = errors.New("notification is being processed")
+
+// defaultClaimLease is how long a claim is held before another delivery may
+// take it over, e.g. after a crash during processing.
+const defaultClaimLease = 5 * time.Minute
+
+type SeenStoreOption func(*seenStoreConfig)
+
+type seenStoreConfig struct {
+	lease       time.Duration
+	placeholder func(n int) string
+}
+
+func newSeenStoreConfig(opts []SeenStoreOption) seenStoreConfig {
+	cfg := seenStoreConfig{
+		lease: defaultClaimLease,
+		placeholder: func(int) string {
+			return "?"
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithClaimLease sets how long a claim is held before a notification that
+// has not been completed or released may be claimed again. The default is 5
+// minutes; it must be longer than processing a notification takes.
+func WithClaimLease(lease time.Duration) SeenStoreOption {
+	return func(cfg *seenStoreConfig) {
+		cfg.lease = lease
+	}
+}
+
+// WithDollarPlaceholders makes a SQLSeenStore use $1 style query
+// placeholders, e.g. for PostgreSQL. The default is ?.
+func WithDollarPlaceholders() SeenStoreOption {
+	return func(cfg *seenStoreConfig) {
+		cfg.placeholder = func(n int) string {
+			return "$" + strconv.Itoa(n)
+		}
+	}
+}
+
+type claimKey struct{}
+
+// Deduplicate runs each notification through the wrapped NotificationFunc
+// only once per notificationUUID, including notifications delivered
+// concurrently. A delivery that arrives while the notification is processed
+// fails with ErrClaimInProgress so that Apple retries it. The claim is
+// released when the NotificationFunc fails so that Apple's retry processes
+// the notification.
+func Deduplicate(store SeenStore) NotificationMiddleware {
+	return func(next NotificationFunc) NotificationFunc {
+		return func(ctx context.Context, p *ResponseBodyV2DecodedPayload) error {
+			// A Deduplicate further out, e.g. in a Reconciler, has the claim.
+			if p.NotificationUUID == "" || ctx.Value(claimKey{}) == p.NotificationUUID {
+				return next(ctx, p)
+			}
+			token, claimed, err := store.Claim(ctx, p.NotificationUUID)
+			if err != nil {
+				return fmt.Errorf("appleapi: could not claim notification %s: %w", p.NotificationUUID, err)
+			}
+			if !claimed {
+				return nil
+			}
+			if err := next(context.WithValue(ctx, claimKey{}, p.NotificationUUID), p); err != nil {
+				if releaseErr := store.Release(context.Background(), p.NotificationUUID, token); releaseErr != nil {
+					return fmt.Errorf("%w; could not release notification %s: %v", err, p.NotificationUUID, releaseErr)
+				}
+				return err
+			}
+			// The notification was processed, so failing the delivery would
+			// only make Apple's retry process it again. Should recording
+			// that fail, the claim lapses after its lease instead.
+			_ = store.Complete(context.Background(), p.NotificationUUID)
+			return nil
+		}
+	}
+}
+
+// MemorySeenStore is a SeenStore that forgets processed notifications after a
+// TTL. Apple retries a notification for up to about three days; the TTL must
+// also cover the range a Reconciler using the store reconciles.
+type MemorySeenStore struct {
+	ttl   time.Duration
+	lease time.Duration
+
+	mu        sync.Mutex
+	claims    map[string]memoryClaim
+	lastSweep time.Time
+}
+
+type memoryClaim struct {
+	token   string
+	done    bool
+	expires time.Time
+}
+
+func NewMemorySeenStore(ttl time.Duration, opts ...SeenStoreOption) *MemorySeenStore {
+	cfg := newSeenStoreConfig(opts)
+	return &MemorySeenStore{ttl: ttl, lease: cfg.lease, claims: make(map[string]memoryClaim)}
+}
+
+func (s *MemorySeenStore) Claim(ctx context.Context, id string) (string, bool, error) {
+	token, err := newClaimToken()
+	if err != nil {
+		return "", false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastSweep) > s.ttl {
+		for k, c := range s.claims {
+			if !now.Before(c.expires) {
+				delete(s.claims, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	if c, ok := s.claims[id]; ok && now.Before(c.expires) {
+		if !c.done {
+			return "", false, ErrClaimInProgress
+		}
+		return "", false, nil
+	}
+	s.claims[id] = memoryClaim{token: token, expires: now.Add(s.lease)}
+	return token, true, nil
+}
+
+func (s *MemorySeenStore) Complete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.claims[id] = memoryClaim{done: true, expires: time.Now().Add(s.ttl)}
+	return nil
+}
+
+func (s *MemorySeenStore) Release(ctx context.Context, id, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.claims[id]; ok && !c.done && c.token == token {
+		delete(s.claims, id)
+	}
+	return nil
+}
+
+func newClaimToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("could not generate claim token: %v", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// SQLSeenStore is a SeenStore backed by a database/sql table with a unique
+// notification_uuid column, e.g.
+//
+//	CREATE TABLE appstore_notifications (
+//		notification_uuid VARCHAR(36) PRIMARY KEY,
+//		claimed_at TIMESTAMP NOT NULL,
+//		claim_token VARCHAR(32) NOT NULL,
+//		completed_at TIMESTAMP NULL
+//	)
+//
+// Expired rows are replaced when their notification is claimed again; delete
+// rows whose completed_at is older than the TTL to keep the table small.
+type SQLSeenStore struct {
+	db          *sql.DB
+	table       string
+	ttl         time.Duration
+	lease       time.Duration
+	placeholder func(n int) string
+}
+
+// NewSQLSeenStore creates a SQLSeenStore that forgets processed notifications
+// after ttl, see MemorySeenStore. table is used in queries as is and must not
+// come from untrusted input.
+func NewSQLSeenStore(db *sql.DB, table string, ttl time.Duration, opts ...SeenStoreOption) *SQLSeenStore {
+	cfg := newSeenStoreConfig(opts)
+	return &SQLSeenStore{
+		db:          db,
+		table:       table,
+		ttl:         ttl,
+		lease:       cfg.lease,
+		placeholder: cfg.placeholder,
+	}
+}
+
+func (s *SQLSeenStore) Claim(ctx context.Context, id string) (string, bool, error) {
+	token, err := newClaimToken()
+	if err != nil {
+		return "", false, err
+	}
+	now := time.Now().UTC()
+	insertErr := s.insert(ctx, id, token, now)
+	if insertErr == nil {
+		return token, true, nil
+	}
+	// Drivers report unique violations differently, so look at the existing
+	// claim to tell why the insert failed. An expired claim is taken over.
+	expire := fmt.Sprintf("DELETE FROM %s WHERE notification_uuid = %s AND "+
+		"((completed_at IS NULL AND claimed_at < %s) OR completed_at < %s)",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3))
+	if res, err := s.db.ExecContext(ctx, expire, id, now.Add(-s.lease), now.Add(-s.ttl)); err == nil {
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			if err := s.insert(ctx, id, token, now); err == nil {
+				return token, true, nil
+			}
+		}
+	}
+	var completedAt sql.NullTime
+	query := fmt.Sprintf("SELECT completed_at FROM %s WHERE notification_uuid = %s", s.table, s.placeholder(1))
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&completedAt); err != nil {
+		return "", false, insertErr
+	}
+	if !completedAt.Valid {
+		return "", false, ErrClaimInProgress
+	}
+	return "", false, nil
+}
+
+func (s *SQLSeenStore) insert(ctx context.Context, id, token string, now time.Time) error {
+	query := fmt.Sprintf("INSERT INTO %s (notification_uuid, claimed_at, claim_token) VALUES (%s, %s, %s)",
+		s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3))
+	_, err := s.db.ExecContext(ctx, query, id, now, token)
+	return err
+}
+
+func (s *SQLSeenStore) Complete(ctx context.Context, id string) error {
+	now := time.Now().UTC()
+	update := fmt.Sprintf("UPDATE %s SET completed_at = %s WHERE notification_uuid = %s AND completed_at IS NULL",
+		s.table, s.placeholder(1), s.placeholder(2))
+	res, err := s.db.ExecContext(ctx, update, now, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+	// The claim was released by a delivery that took it over and failed, or
+	// completed already. Recording completion a second time fails harmlessly.
+	insert := fmt.Sprintf("INSERT INTO %s (notification_uuid, claimed_at, claim_token, completed_at) "+
+		"VALUES (%s, %s, %s, %s)", s.table, s.placeholder(1), s.placeholder(2), s.placeholder(3), s.placeholder(4))
+	_, _ = s.db.ExecContext(ctx, insert, id, now, "", now)
+	return nil
+}
+
+func (s *SQLSeenStore) Release(ctx context.Context, id, token string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE notification_uuid = %s AND claim_token = %s AND completed_at IS NULL",
+		s.table, s.placeholder(1), s.placeholder(2))
+	_, err := s.db.ExecContext(ctx, query, id, token)
+	return err
+}
@@ -0,0 +1,272 @@
+package appstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeduplicateRetryDuringProcessing(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemorySeenStore(time.Hour)
+	p := &ResponseBodyV2DecodedPayload{NotificationUUID: "002e14d5-51f5-4503-b5a8-c3a1af68eb20"}
+
+	started := make(chan struct{})
+	fail := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		first <- Deduplicate(store)(func(context.Context, *ResponseBodyV2DecodedPayload) error {
+			close(started)
+			<-fail
+			return errors.New("processing failed")
+		})(ctx, p)
+	}()
+	<-started
+
+	runs := 0
+	process := Deduplicate(store)(func(context.Context, *ResponseBodyV2DecodedPayload) error {
+		runs++
+		return nil
+	})
+	if err := process(ctx, p); !errors.Is(err, ErrClaimInProgress) {
+		t.Fatalf("retry during processing: got %v, want ErrClaimInProgress", err)
+	}
+	close(fail)
+	if err := <-first; err == nil {
+		t.Fatal("first delivery: got nil error")
+	}
+	if err := process(ctx, p); err != nil {
+		t.Fatalf("retry after failure: %v", err)
+	}
+	if err := process(ctx, p); err != nil {
+		t.Fatalf("retry after success: %v", err)
+	}
+	if runs != 1 {
+		t.Errorf("processed %d times after the failure, want 1", runs)
+	}
+}
+
+func TestMemorySeenStore(t *testing.T) {
+	testSeenStore(t, func(t *testing.T, ttl, lease time.Duration) SeenStore {
+		return NewMemorySeenStore(ttl, WithClaimLease(lease))
+	})
+}
+
+func TestSQLSeenStore(t *testing.T) {
+	testSeenStore(t, func(t *testing.T, ttl, lease time.Duration) SeenStore {
+		db, err := sql.Open(fakeSQLDriverName, t.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return NewSQLSeenStore(db, "appstore_notifications", ttl, WithClaimLease(lease))
+	})
+}
+
+// testSeenStore runs the SeenStore contract against stores made by newStore.
+func testSeenStore(t *testing.T, newStore func(t *testing.T, ttl, lease time.Duration) SeenStore) {
+	ctx := context.Background()
+	const (
+		id    = "e2b8bcc0-2fc9-4d05-a2c1-bd2c62da23cc"
+		lease = 20 * time.Millisecond
+		ttl   = 200 * time.Millisecond
+	)
+	claim := func(t *testing.T, store SeenStore, wantOK bool, wantErr error) string {
+		t.Helper()
+		token, ok, err := store.Claim(ctx, id)
+		if ok != wantOK || !errors.Is(err, wantErr) {
+			t.Fatalf("claim: got %v, %v, want %v, %v", ok, err, wantOK, wantErr)
+		}
+		return token
+	}
+
+	t.Run("release", func(t *testing.T) {
+		store := newStore(t, ttl, time.Hour)
+		token := claim(t, store, true, nil)
+		claim(t, store, false, ErrClaimInProgress)
+		if err := store.Release(ctx, id, token); err != nil {
+			t.Fatal(err)
+		}
+		claim(t, store, true, nil)
+	})
+
+	t.Run("takeover", func(t *testing.T) {
+		store := newStore(t, ttl, lease)
+		tokenA := claim(t, store, true, nil)
+		time.Sleep(2 * lease)
+		tokenB := claim(t, store, true, nil)
+
+		// A overran its lease; its release must not drop B's claim.
+		if err := store.Release(ctx, id, tokenA); err != nil {
+			t.Fatal(err)
+		}
+		claim(t, store, false, ErrClaimInProgress)
+
+		// A succeeding marks the notification processed, so B failing must
+		// not make it available again.
+		if err := store.Complete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Release(ctx, id, tokenB); err != nil {
+			t.Fatal(err)
+		}
+		claim(t, store, false, nil)
+	})
+
+	t.Run("complete after takeover released", func(t *testing.T) {
+		store := newStore(t, ttl, lease)
+		claim(t, store, true, nil)
+		time.Sleep(2 * lease)
+		tokenB := claim(t, store, true, nil)
+		if err := store.Release(ctx, id, tokenB); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Complete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		claim(t, store, false, nil)
+	})
+
+	t.Run("ttl", func(t *testing.T) {
+		store := newStore(t, ttl, lease)
+		claim(t, store, true, nil)
+		if err := store.Complete(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		claim(t, store, false, nil)
+		time.Sleep(2 * ttl)
+		claim(t, store, true, nil)
+	})
+}
+
+const fakeSQLDriverName = "appstore-fake"
+
+func init() {
+	sql.Register(fakeSQLDriverName, &fakeSQLDriver{tables: make(map[string]map[string]*fakeSQLRow)})
+}
+
+// fakeSQLDriver runs the statements SQLSeenStore issues against an in-memory
+// table per data source name, as no SQL database is available to tests.
+type fakeSQLDriver struct {
+	mu     sync.Mutex
+	tables map[string]map[string]*fakeSQLRow
+}
+
+type fakeSQLRow struct {
+	claimedAt   time.Time
+	claimToken  string
+	completedAt *time.Time
+}
+
+func (d *fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.tables[name] == nil {
+		d.tables[name] = make(map[string]*fakeSQLRow)
+	}
+	return &fakeSQLConn{driver: d, rows: d.tables[name]}, nil
+}
+
+type fakeSQLConn struct {
+	driver *fakeSQLDriver
+	rows   map[string]*fakeSQLRow
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fake SQL driver: prepared statements not supported")
+}
+
+func (c *fakeSQLConn) Close() error { return nil }
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fake SQL driver: transactions not supported")
+}
+
+func (c *fakeSQLConn) ExecContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Result, error) {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	args := make([]driver.Value, len(named))
+	for i, a := range named {
+		args[i] = a.Value
+	}
+	id, ok := args[0].(string)
+	if !ok {
+		// UPDATE binds completed_at before notification_uuid.
+		id = args[1].(string)
+	}
+	row, exists := c.rows[id]
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO"):
+		if exists {
+			return nil, errors.New("UNIQUE constraint failed: notification_uuid")
+		}
+		row = &fakeSQLRow{claimedAt: args[1].(time.Time), claimToken: args[2].(string)}
+		if len(args) == 4 {
+			completedAt := args[3].(time.Time)
+			row.completedAt = &completedAt
+		}
+		c.rows[id] = row
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(query, "DELETE FROM") && strings.Contains(query, "claimed_at <"):
+		if exists && ((row.completedAt == nil && row.claimedAt.Before(args[1].(time.Time))) ||
+			(row.completedAt != nil && row.completedAt.Before(args[2].(time.Time)))) {
+			delete(c.rows, id)
+			return driver.RowsAffected(1), nil
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "DELETE FROM") && strings.Contains(query, "claim_token ="):
+		if exists && row.completedAt == nil && row.claimToken == args[1].(string) {
+			delete(c.rows, id)
+			return driver.RowsAffected(1), nil
+		}
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(query, "UPDATE"):
+		if exists && row.completedAt == nil {
+			completedAt := args[0].(time.Time)
+			row.completedAt = &completedAt
+			return driver.RowsAffected(1), nil
+		}
+		return driver.RowsAffected(0), nil
+	}
+	return nil, fmt.Errorf("fake SQL driver: unexpected statement %q", query)
+}
+
+func (c *fakeSQLConn) QueryContext(ctx context.Context, query string, named []driver.NamedValue) (driver.Rows, error) {
+	if !strings.HasPrefix(query, "SELECT completed_at FROM") {
+		return nil, fmt.Errorf("fake SQL driver: unexpected query %q", query)
+	}
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	rows := &fakeSQLRows{}
+	if row, ok := c.rows[named[0].Value.(string)]; ok {
+		var completedAt driver.Value
+		if row.completedAt != nil {
+			completedAt = *row.completedAt
+		}
+		rows.values = append(rows.values, completedAt)
+	}
+	return rows, nil
+}
+
+type fakeSQLRows struct {
+	values []driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string { return []string{"completed_at"} }
+
+func (r *fakeSQLRows) Close() error { return nil }
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
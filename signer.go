@@ -0,0 +1,66 @@
+package appstore
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const es256KeySize = 32
+
+// signingMethodSigner is jwt.SigningMethodES256 for keys that are only
+// reachable through crypto.Signer, such as keys held in a KMS or HSM.
+type signingMethodSigner struct{}
+
+var signingMethodES256Signer = &signingMethodSigner{}
+
+func (m *signingMethodSigner) Alg() string {
+	return jwt.SigningMethodES256.Alg()
+}
+
+func (m *signingMethodSigner) Verify(signingString, signature string, key any) error {
+	return jwt.SigningMethodES256.Verify(signingString, signature, key)
+}
+
+func (m *signingMethodSigner) Sign(signingString string, key any) (string, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	digest := sha256.Sum256([]byte(signingString))
+	der, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	sig, err := JOSESignature(der)
+	if err != nil {
+		return "", err
+	}
+	return jwt.EncodeSegment(sig), nil
+}
+
+// JOSESignature converts an ASN.1 DER encoded ECDSA P-256 signature, as
+// returned by crypto.Signer, to the fixed size r||s form JWS expects.
+func JOSESignature(der []byte) ([]byte, error) {
+	var parsed struct{ R, S *big.Int }
+	rest, err := asn1.Unmarshal(der, &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse ASN.1 signature: %v", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after ASN.1 signature")
+	}
+	if parsed.R.Sign() <= 0 || parsed.S.Sign() <= 0 ||
+		parsed.R.BitLen() > es256KeySize*8 || parsed.S.BitLen() > es256KeySize*8 {
+		return nil, fmt.Errorf("signature is not a P-256 signature")
+	}
+	sig := make([]byte, 2*es256KeySize)
+	parsed.R.FillBytes(sig[:es256KeySize])
+	parsed.S.FillBytes(sig[es256KeySize:])
+	return sig, nil
+}
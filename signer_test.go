@@ -0,0 +1,109 @@
+package appstore
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// recordingSigner exposes only crypto.Signer, like a KMS or HSM key, and
+// keeps the last DER signature it made.
+type recordingSigner struct {
+	key *ecdsa.PrivateKey
+	der []byte
+}
+
+func (s *recordingSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *recordingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	der, err := s.key.Sign(rand, digest, opts)
+	s.der = der
+	return der, err
+}
+
+func TestSigningMethodSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := &recordingSigner{key: key}
+
+	// About one signature in 128 has an r or s shorter than 32 bytes, which
+	// must be left padded.
+	short := false
+	for i := 0; i < 5000 && !short; i++ {
+		token := jwt.NewWithClaims(signingMethodES256Signer, jwt.RegisteredClaims{Issuer: "issuer"})
+		signed, err := token.SignedString(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := strings.Split(signed, ".")
+		if err := jwt.SigningMethodES256.Verify(parts[0]+"."+parts[1], parts[2], &key.PublicKey); err != nil {
+			t.Fatalf("signature %d: %v", i, err)
+		}
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signer.der, &sig); err != nil {
+			t.Fatal(err)
+		}
+		short = sig.R.BitLen() <= 248 || sig.S.BitLen() <= 248
+	}
+	if !short {
+		t.Fatal("no signature with a short r or s")
+	}
+}
+
+func TestJOSESignature(t *testing.T) {
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(1), big.NewInt(0x0203)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := JOSESignature(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 64)
+	want[31] = 0x01
+	want[62], want[63] = 0x02, 0x03
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %x, want %x", got, want)
+	}
+}
+
+func TestJOSESignatureInvalid(t *testing.T) {
+	tooLong := new(big.Int).Lsh(big.NewInt(1), 256)
+	valid, err := asn1.Marshal(struct{ R, S *big.Int }{big.NewInt(1), big.NewInt(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct{ R, S *big.Int }{
+		"zero r":     {big.NewInt(0), big.NewInt(1)},
+		"negative s": {big.NewInt(1), big.NewInt(-1)},
+		"long r":     {tooLong, big.NewInt(1)},
+	}
+	for name, sig := range tests {
+		der, err := asn1.Marshal(sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := JOSESignature(der); err == nil {
+			t.Errorf("%s: got nil error", name)
+		}
+	}
+	if _, err := JOSESignature(append(valid, 0)); err == nil {
+		t.Error("trailing data: got nil error")
+	}
+	if _, err := JOSESignature([]byte("not DER")); err == nil {
+		t.Error("not DER: got nil error")
+	}
+}
@@ -0,0 +1,51 @@
+package subscription
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+type Status int32
+
+const (
+	Active       Status = 1
+	Expired      Status = 2
+	BillingRetry Status = 3
+	GracePeriod  Status = 4
+	Revoked      Status = 5
+)
+
+var statusNames = map[Status]string{
+	Active:       "ACTIVE",
+	Expired:      "EXPIRED",
+	BillingRetry: "BILLING_RETRY",
+	GracePeriod:  "BILLING_GRACE_PERIOD",
+	Revoked:      "REVOKED",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Status(%d)", int32(s))
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	return json.Marshal(int32(s))
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*int32)(s))
+}
+
+type StatusOption func(*url.Values)
+
+func WithStatus(statuses ...Status) StatusOption {
+	return func(query *url.Values) {
+		for _, s := range statuses {
+			query.Add("status", strconv.FormatInt(int64(s), 10))
+		}
+	}
+}
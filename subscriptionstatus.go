@@ -7,10 +7,10 @@ import (
 )
 
 type LastTransactionsItem struct {
-	OriginalTransactionId string  `json:"originalTransactionId"`
-	Status                int32   `json:"status"`
-	SignedRenewalInfo     JWSData `json:"signedRenewalInfo"`
-	SignedTransactionInfo JWSData `json:"signedTransactionInfo"`
+	OriginalTransactionId string             `json:"originalTransactionId"`
+	Status                SubscriptionStatus `json:"status"`
+	SignedRenewalInfo     JWSData            `json:"signedRenewalInfo"`
+	SignedTransactionInfo JWSData            `json:"signedTransactionInfo"`
 
 	RenewalInfo     JWSRenewalInfoDecodedPayload
 	TransactionInfo JWSTransactionDecodedPayload
@@ -0,0 +1,44 @@
+package appstore
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	tokenLifetime             = 15 * time.Minute
+	defaultTokenRefreshMargin = time.Minute
+)
+
+// tokenSource caches the signed API bearer token and signs a new one when the
+// cached token is within margin of expiring. It is safe for concurrent use.
+type tokenSource struct {
+	margin time.Duration
+	sign   func(issuedAt time.Time) (string, error)
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func (s *tokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.token != "" && now.Before(s.expiresAt.Add(-s.margin)) {
+		return s.token, nil
+	}
+	token, err := s.sign(now)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	s.expiresAt = now.Add(tokenLifetime)
+	return token, nil
+}
+
+func (s *tokenSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
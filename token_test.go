@@ -0,0 +1,51 @@
+package appstore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithTokenRefreshMargin(t *testing.T) {
+	tests := []struct {
+		margin time.Duration
+		want   time.Duration
+	}{
+		{0, 0},
+		{5 * time.Minute, 5 * time.Minute},
+		{-time.Minute, defaultTokenRefreshMargin},
+		{tokenLifetime, defaultTokenRefreshMargin},
+		{time.Hour, defaultTokenRefreshMargin},
+	}
+	for _, tt := range tests {
+		c, err := NewClient(WithTokenRefreshMargin(tt.margin))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.tokens.margin != tt.want {
+			t.Errorf("WithTokenRefreshMargin(%v): got margin %v, want %v", tt.margin, c.tokens.margin, tt.want)
+		}
+	}
+}
+
+func TestTokenSourceReusesToken(t *testing.T) {
+	signed := 0
+	s := &tokenSource{
+		margin: defaultTokenRefreshMargin,
+		sign: func(time.Time) (string, error) {
+			signed++
+			return "token", nil
+		},
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.Token(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Invalidate()
+	if _, err := s.Token(); err != nil {
+		t.Fatal(err)
+	}
+	if signed != 2 {
+		t.Errorf("signed %d tokens, want 2", signed)
+	}
+}
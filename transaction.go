@@ -35,3 +35,35 @@ func (r *HistoryResponse) DecodeJWS(keyFunc jwt.Keyfunc, data []byte) error {
 	}
 	return nil
 }
+
+type TransactionInfoResponse struct {
+	SignedTransactionInfo JWSData `json:"signedTransactionInfo"`
+
+	TransactionInfo JWSTransactionDecodedPayload
+}
+
+func (r *TransactionInfoResponse) DecodeJWS(keyFunc jwt.Keyfunc, data []byte) error {
+	if err := json.Unmarshal(data, r); err != nil {
+		return err
+	}
+	if err := r.SignedTransactionInfo.Decode(keyFunc, &r.TransactionInfo); err != nil {
+		return err
+	}
+	return nil
+}
+
+type AppTransactionInfoResponse struct {
+	SignedAppTransactionInfo JWSData `json:"signedAppTransactionInfo"`
+
+	AppTransaction JWSAppTransactionDecodedPayload
+}
+
+func (r *AppTransactionInfoResponse) DecodeJWS(keyFunc jwt.Keyfunc, data []byte) error {
+	if err := json.Unmarshal(data, r); err != nil {
+		return err
+	}
+	if err := r.SignedAppTransactionInfo.Decode(keyFunc, &r.AppTransaction); err != nil {
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package transaction
 
 import (
+	"encoding/json"
 	"net/url"
 	"strconv"
 	"time"
@@ -8,6 +9,41 @@ import (
 
 type HistoryOption func(*url.Values)
 
+type ProductType string
+
+const (
+	AutoRenewable ProductType = "AUTO_RENEWABLE"
+	NonRenewable  ProductType = "NON_RENEWABLE"
+	Consumable    ProductType = "CONSUMABLE"
+	NonConsumable ProductType = "NON_CONSUMABLE"
+)
+
+type Sort string
+
+const (
+	Ascending  Sort = "ASCENDING"
+	Descending Sort = "DESCENDING"
+)
+
+type InAppOwnershipType string
+
+const (
+	FamilyShared InAppOwnershipType = "FAMILY_SHARED"
+	Purchased    InAppOwnershipType = "PURCHASED"
+)
+
+func (t InAppOwnershipType) String() string {
+	return string(t)
+}
+
+func (t InAppOwnershipType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func (t *InAppOwnershipType) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(t))
+}
+
 func WithStartDate(t time.Time) HistoryOption {
 	return func(query *url.Values) {
 		query.Set("startDate", strconv.FormatInt(t.UnixMilli(), 10))
@@ -35,20 +71,46 @@ func WithProductIDs(ids ...string) HistoryOption {
 	}
 }
 
-func WithProductTypes(types ...string) HistoryOption {
+func WithProductTypes(types ...ProductType) HistoryOption {
 	return func(query *url.Values) {
 		for _, t := range types {
-			query.Add("productType", t)
+			query.Add("productType", string(t))
 		}
 	}
 }
 
-func WithExcludeRevoked(excludeRevoked bool) HistoryOption {
+func WithSort(sort Sort) HistoryOption {
+	return func(query *url.Values) {
+		query.Set("sort", string(sort))
+	}
+}
+
+func WithSubscriptionGroupIdentifiers(ids ...string) HistoryOption {
 	return func(query *url.Values) {
-		val := "false"
-		if excludeRevoked {
-			val = "true"
+		for _, i := range ids {
+			query.Add("subscriptionGroupIdentifier", i)
 		}
-		query.Set("excludeRevoked", val)
+	}
+}
+
+func WithInAppOwnershipType(ownershipType InAppOwnershipType) HistoryOption {
+	return func(query *url.Values) {
+		query.Set("inAppOwnershipType", string(ownershipType))
+	}
+}
+
+// WithExcludeRevoked only applies to the deprecated v1 endpoint, use
+// WithRevoked with GetTransactionHistoryV2.
+func WithExcludeRevoked(excludeRevoked bool) HistoryOption {
+	return func(query *url.Values) {
+		query.Set("excludeRevoked", strconv.FormatBool(excludeRevoked))
+	}
+}
+
+// WithRevoked limits results to revoked transactions when true, or to
+// transactions that are not revoked when false.
+func WithRevoked(revoked bool) HistoryOption {
+	return func(query *url.Values) {
+		query.Set("revoked", strconv.FormatBool(revoked))
 	}
 }
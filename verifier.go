@@ -0,0 +1,133 @@
+package appstore
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type Environment string
+
+const (
+	EnvironmentProduction   Environment = "Production"
+	EnvironmentSandbox      Environment = "Sandbox"
+	EnvironmentXcode        Environment = "Xcode"
+	EnvironmentLocalTesting Environment = "LocalTesting"
+)
+
+// SignedDataVerifier verifies and decodes JWS payloads signed by the App Store
+// without needing App Store Server API credentials, e.g. in a notification
+// receiver or when validating transactions sent by an app.
+type SignedDataVerifier struct {
+	appAppleID  int64
+	bundleID    string
+	environment Environment
+
+	chainKeyFunc jwt.Keyfunc
+}
+
+// NewSignedDataVerifier creates a verifier that trusts the given Apple root
+// certificates, e.g. Apple Root CA - G3, each PEM or DER encoded. appAppleID
+// is unknown for apps that have not been released and may be zero outside of
+// the production environment.
+func NewSignedDataVerifier(rootCerts [][]byte, bundleID string, appAppleID int64, environment Environment,
+	opts ...VerifierOption) (*SignedDataVerifier, error) {
+
+	if len(rootCerts) == 0 {
+		return nil, fmt.Errorf("appleapi: no Apple root certificates given")
+	}
+	if environment == EnvironmentProduction && appAppleID == 0 {
+		return nil, fmt.Errorf("appleapi: app Apple ID is required in the production environment")
+	}
+	rootPool := x509.NewCertPool()
+	for i, data := range rootCerts {
+		certs, err := parseCertificates(data)
+		if err != nil {
+			return nil, fmt.Errorf("appleapi: root certificate argument %d: %v", i, err)
+		}
+		for _, cert := range certs {
+			rootPool.AddCert(cert)
+		}
+	}
+	return &SignedDataVerifier{
+		appAppleID:   appAppleID,
+		bundleID:     bundleID,
+		environment:  environment,
+		chainKeyFunc: newKeyFunc(&x509.VerifyOptions{Roots: rootPool}, opts...),
+	}, nil
+}
+
+func (v *SignedDataVerifier) VerifyTransaction(signedTransaction string) (*JWSTransactionDecodedPayload, error) {
+	var p JWSTransactionDecodedPayload
+	if err := JWSData(signedTransaction).Decode(v.keyFunc, &p); err != nil {
+		return nil, fmt.Errorf("appleapi: verifier VerifyTransaction: %w", err)
+	}
+	return &p, nil
+}
+
+func (v *SignedDataVerifier) VerifyRenewalInfo(signedRenewalInfo string) (*JWSRenewalInfoDecodedPayload, error) {
+	var p JWSRenewalInfoDecodedPayload
+	if err := JWSData(signedRenewalInfo).Decode(v.keyFunc, &p); err != nil {
+		return nil, fmt.Errorf("appleapi: verifier VerifyRenewalInfo: %w", err)
+	}
+	return &p, nil
+}
+
+func (v *SignedDataVerifier) VerifyAppTransaction(signedAppTransaction string) (*JWSAppTransactionDecodedPayload, error) {
+	var p JWSAppTransactionDecodedPayload
+	if err := JWSData(signedAppTransaction).Decode(v.keyFunc, &p); err != nil {
+		return nil, fmt.Errorf("appleapi: verifier VerifyAppTransaction: %w", err)
+	}
+	return &p, nil
+}
+
+// VerifyNotification verifies the signedPayload of an App Store Server
+// Notification along with the transaction and renewal info nested in it.
+func (v *SignedDataVerifier) VerifyNotification(signedPayload string) (*ResponseBodyV2DecodedPayload, error) {
+	p, err := decodeNotification(v.keyFunc, JWSData(signedPayload))
+	if err != nil {
+		return nil, fmt.Errorf("appleapi: verifier VerifyNotification: %w", err)
+	}
+	return p, nil
+}
+
+// appClaims is implemented by decoded payloads that identify the app and
+// environment they were issued for. Empty values are not checked.
+type appClaims interface {
+	appClaims() (bundleID string, appAppleID int64, environment string)
+}
+
+// keyFunc verifies the certificate chain of a JWS and, since the claims are
+// already decoded by the time the key is looked up, rejects payloads issued
+// for another app or environment.
+func (v *SignedDataVerifier) keyFunc(t *jwt.Token) (any, error) {
+	key, err := v.chainKeyFunc(t)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := t.Claims.(appClaims); ok {
+		if err := v.checkApp(c.appClaims()); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+func (v *SignedDataVerifier) checkApp(bundleID string, appAppleID int64, environment string) error {
+	if bundleID != "" && v.bundleID != "" && bundleID != v.bundleID {
+		return &ClaimMismatchError{Claim: "bundleId", Expected: v.bundleID, Actual: bundleID}
+	}
+	if appAppleID != 0 && v.appAppleID != 0 && appAppleID != v.appAppleID {
+		return &ClaimMismatchError{
+			Claim:    "appAppleId",
+			Expected: strconv.FormatInt(v.appAppleID, 10),
+			Actual:   strconv.FormatInt(appAppleID, 10),
+		}
+	}
+	if environment != "" && Environment(environment) != v.environment {
+		return &ClaimMismatchError{Claim: "environment", Expected: string(v.environment), Actual: environment}
+	}
+	return nil
+}
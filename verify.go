@@ -0,0 +1,150 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Marker extensions Apple places on the certificates that sign App Store
+// payloads. See https://www.apple.com/certificateauthority/pdf/Apple_WWDR_CPS_v1.pdf
+var (
+	oidAppleLeafMarker         = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	oidAppleIntermediateMarker = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+type VerifierOption func(*verifierConfig)
+
+type verifierConfig struct {
+	ocsp    *OCSPChecker
+	offline bool
+}
+
+// WithOCSP checks that the leaf and intermediate certificates of each JWS
+// have not been revoked.
+func WithOCSP(checker *OCSPChecker) VerifierOption {
+	return func(cfg *verifierConfig) {
+		cfg.ocsp = checker
+	}
+}
+
+// WithOfflineVerification skips revocation checks and verifies certificate
+// chains as of the payload's signedDate instead of the current time, so
+// stored payloads still verify after their signing certificate expires.
+func WithOfflineVerification() VerifierOption {
+	return func(cfg *verifierConfig) {
+		cfg.offline = true
+	}
+}
+
+// signedDater is implemented by decoded payloads that carry a signedDate.
+type signedDater interface {
+	signedDate() time.Time
+}
+
+// newKeyFunc returns a jwt.Keyfunc that only hands back the signing key once
+// the x5c chain in the JWS header verifies against opts.
+func newKeyFunc(opts *x509.VerifyOptions, verifierOpts ...VerifierOption) jwt.Keyfunc {
+	var cfg verifierConfig
+	for _, opt := range verifierOpts {
+		opt(&cfg)
+	}
+	return func(t *jwt.Token) (any, error) {
+		if opts == nil {
+			return nil, fmt.Errorf("Apple root certificates not configured, see WithAppleRootCerts")
+		}
+		if alg := t.Method.Alg(); alg != jwt.SigningMethodES256.Alg() {
+			return nil, fmt.Errorf("unexpected JWS signing method: %s", alg)
+		}
+		chain, err := parseCertChain(t.Header)
+		if err != nil {
+			return nil, err
+		}
+		verifyOpts := *opts
+		if d, ok := t.Claims.(signedDater); ok && cfg.offline {
+			verifyOpts.CurrentTime = d.signedDate()
+		}
+		verified, err := verifyCertChain(chain, verifyOpts)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.ocsp != nil && !cfg.offline {
+			for i := 0; i < 2; i++ {
+				if err := cfg.ocsp.Check(verified[i], verified[i+1]); err != nil {
+					return nil, fmt.Errorf("JWS cert revocation check failed: %w", err)
+				}
+			}
+		}
+		key, ok := verified[0].PublicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unexpected JWS cert key type: %T", verified[0].PublicKey)
+		}
+		return key, nil
+	}
+}
+
+func parseCertChain(header map[string]any) ([]*x509.Certificate, error) {
+	multi, ok := header["x5c"].([]any)
+	if !ok {
+		return nil, fmt.Errorf("cert not found in JWS header")
+	}
+	if len(multi) != 3 {
+		return nil, fmt.Errorf("expected 3 certs in JWS header, got %d", len(multi))
+	}
+	chain := make([]*x509.Certificate, 0, len(multi))
+	for i, m := range multi {
+		if encoded, ok := m.(string); !ok {
+			return nil, fmt.Errorf("invalid cert format in JWS header at index %d", i)
+		} else if decoded, err := base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("unable base64 decode JWS cert at index %d: %v", i, err)
+		} else if cert, err := x509.ParseCertificate(decoded); err != nil {
+			return nil, fmt.Errorf("could not parse JWS cert at index %d: %v", i, err)
+		} else {
+			chain = append(chain, cert)
+		}
+	}
+	return chain, nil
+}
+
+// verifyCertChain checks that chain, ordered leaf, intermediate, root as in
+// the x5c header, leads to one of the trusted roots in opts and carries the
+// Apple marker extensions. It returns the verified chain, ending in the
+// trusted root.
+func verifyCertChain(chain []*x509.Certificate, opts x509.VerifyOptions) ([]*x509.Certificate, error) {
+	leaf, interm := chain[0], chain[1]
+	if !hasExtension(leaf, oidAppleLeafMarker) {
+		return nil, fmt.Errorf("JWS leaf cert is missing the Apple marker extension")
+	}
+	if !hasExtension(interm, oidAppleIntermediateMarker) {
+		return nil, fmt.Errorf("JWS intermediate cert is missing the Apple marker extension")
+	}
+
+	intermPool := x509.NewCertPool()
+	intermPool.AddCert(interm)
+	opts.Intermediates = intermPool
+	// Apple's signing certs do not carry the server auth usage Verify
+	// checks for by default.
+	opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	verified, err := leaf.Verify(opts)
+	if err != nil {
+		return nil, fmt.Errorf("could not verify JWS cert chain: %v", err)
+	}
+	if len(verified[0]) != 3 {
+		return nil, fmt.Errorf("unexpected JWS cert chain length: %d", len(verified[0]))
+	}
+	return verified[0], nil
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package appstore
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const testBundleID = "com.example.app"
+
+// asn1Null is the value Apple gives its marker extensions.
+var asn1Null = []byte{0x05, 0x00}
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate from template issued by parent, or
+// self-signed when parent is nil.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	issuer, issuerKey := template, key
+	if parent != nil {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func caTemplate(name string, markers ...pkix.Extension) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtraExtensions:       markers,
+	}
+}
+
+func leafTemplate(name string, markers ...pkix.Extension) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:         pkix.Name{CommonName: name},
+		KeyUsage:        x509.KeyUsageDigitalSignature,
+		ExtraExtensions: markers,
+	}
+}
+
+var (
+	leafMarker         = pkix.Extension{Id: oidAppleLeafMarker, Value: asn1Null}
+	intermediateMarker = pkix.Extension{Id: oidAppleIntermediateMarker, Value: asn1Null}
+)
+
+type testChain struct {
+	root, interm, leaf *testCert
+}
+
+// newTestChain creates a chain shaped like Apple's, with the marker
+// extensions in place. Certificates name ocspURL as their OCSP responder
+// when it is not empty.
+func newTestChain(t *testing.T, ocspURL string) testChain {
+	t.Helper()
+	root := newTestCert(t, caTemplate("Test Root CA"), nil)
+	intermTemplate := caTemplate("Test Intermediate CA", intermediateMarker)
+	leafTmpl := leafTemplate("Test Signing", leafMarker)
+	if ocspURL != "" {
+		intermTemplate.OCSPServer = []string{ocspURL}
+		leafTmpl.OCSPServer = []string{ocspURL}
+	}
+	interm := newTestCert(t, intermTemplate, root)
+	leaf := newTestCert(t, leafTmpl, interm)
+	return testChain{root: root, interm: interm, leaf: leaf}
+}
+
+func (c testChain) x5c() []*x509.Certificate {
+	return []*x509.Certificate{c.leaf.cert, c.interm.cert, c.root.cert}
+}
+
+// signTestJWS signs claims with key and puts x5c in the header.
+func signTestJWS(t *testing.T, key *ecdsa.PrivateKey, x5c []*x509.Certificate, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	encoded := make([]string, 0, len(x5c))
+	for _, cert := range x5c {
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+	token.Header["x5c"] = encoded
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// testTransaction returns transaction claims in Apple's wire format.
+func testTransaction() jwt.MapClaims {
+	return jwt.MapClaims{
+		"transactionId": "2000000000000001",
+		"bundleId":      testBundleID,
+		"environment":   string(EnvironmentSandbox),
+		"signedDate":    time.Now().UnixMilli(),
+	}
+}
+
+func newTestVerifier(t *testing.T, root *x509.Certificate, opts ...VerifierOption) *SignedDataVerifier {
+	t.Helper()
+	v, err := NewSignedDataVerifier([][]byte{root.Raw}, testBundleID, 0, EnvironmentSandbox, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	chain := newTestChain(t, "")
+	v := newTestVerifier(t, chain.root.cert)
+	signed := signTestJWS(t, chain.leaf.key, chain.x5c(), testTransaction())
+
+	p, err := v.VerifyTransaction(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.TransactionID != "2000000000000001" {
+		t.Errorf("got transactionId %q", p.TransactionID)
+	}
+}
+
+func TestVerifyTransactionRejectsChain(t *testing.T) {
+	chain := newTestChain(t, "")
+
+	selfSigned := newTestCert(t, leafTemplate("Self Signed", leafMarker), nil)
+	noLeafMarker := newTestCert(t, leafTemplate("No Marker"), chain.interm)
+	noIntermMarker := newTestCert(t, caTemplate("No Marker CA"), chain.root)
+	noIntermMarkerLeaf := newTestCert(t, leafTemplate("Test Signing", leafMarker), noIntermMarker)
+	untrusted := newTestChain(t, "")
+
+	tests := []struct {
+		name string
+		key  *ecdsa.PrivateKey
+		x5c  []*x509.Certificate
+	}{
+		{"self-signed leaf", selfSigned.key,
+			[]*x509.Certificate{selfSigned.cert, chain.interm.cert, chain.root.cert}},
+		{"self-signed chain", selfSigned.key,
+			[]*x509.Certificate{selfSigned.cert, selfSigned.cert, selfSigned.cert}},
+		{"leaf missing marker", noLeafMarker.key,
+			[]*x509.Certificate{noLeafMarker.cert, chain.interm.cert, chain.root.cert}},
+		{"intermediate missing marker", noIntermMarkerLeaf.key,
+			[]*x509.Certificate{noIntermMarkerLeaf.cert, noIntermMarker.cert, chain.root.cert}},
+		{"untrusted root", untrusted.leaf.key, untrusted.x5c()},
+		{"untrusted root claiming trusted root", untrusted.leaf.key,
+			[]*x509.Certificate{untrusted.leaf.cert, untrusted.interm.cert, chain.root.cert}},
+		{"two certs", chain.leaf.key, []*x509.Certificate{chain.leaf.cert, chain.interm.cert}},
+		{"signed by another key", untrusted.leaf.key, chain.x5c()},
+	}
+	v := newTestVerifier(t, chain.root.cert)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := signTestJWS(t, tt.key, tt.x5c, testTransaction())
+			if p, err := v.VerifyTransaction(signed); err == nil {
+				t.Fatalf("verified forged transaction %+v", p)
+			}
+		})
+	}
+}
+
+func TestVerifyTransactionRejectsNoX5c(t *testing.T) {
+	chain := newTestChain(t, "")
+	v := newTestVerifier(t, chain.root.cert)
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodES256, testTransaction()).SignedString(chain.leaf.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.VerifyTransaction(signed); err == nil {
+		t.Fatal("verified transaction without x5c header")
+	}
+}
+
+func TestVerifyTransactionRejectsOtherApp(t *testing.T) {
+	chain := newTestChain(t, "")
+	v := newTestVerifier(t, chain.root.cert)
+	p := testTransaction()
+	p["bundleId"] = "com.example.other"
+	signed := signTestJWS(t, chain.leaf.key, chain.x5c(), p)
+
+	_, err := v.VerifyTransaction(signed)
+	var mismatch *ClaimMismatchError
+	if !errors.As(err, &mismatch) || mismatch.Claim != "bundleId" {
+		t.Fatalf("got %v, want bundleId ClaimMismatchError", err)
+	}
+}